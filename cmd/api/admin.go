@@ -0,0 +1,361 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/data"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+)
+
+const permissionUsersAdmin = "users:admin"
+
+// listUsersHandler lists users. Admins who belong to an organization, as
+// every admin invited into one does, only see and manage the users of that
+// organization. Admins without one, who can only be granted users:admin
+// directly in the database, manage every user.
+func (app *application) listUsersHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	var input data.UserFilters
+
+	input.OrganizationID = app.contextGetUser(r).OrganizationID
+
+	input.Email = app.readString(&qs, "email", "")
+	input.Activated = app.readBool(&qs, "activated", v)
+	input.CreatedAfter = app.readTime(&qs, "created_after", v)
+	input.CreatedBefore = app.readTime(&qs, "created_before", v)
+
+	input.Page = app.readInt(&qs, "page", 1, v)
+	input.PageSize = app.readInt(&qs, "page_size", 20, v)
+	input.Sort = app.readString(&qs, "sort", "id")
+
+	input.SortSafelist = []string{"id", "name", "email", "created_at", "-id", "-name", "-email", "-created_at"}
+
+	if data.ValidateFilter(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	users, metadata, err := app.models.Users.GetAll(input)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"users": users, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.readUserParam(w, r)
+	if !ok {
+		return
+	}
+
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("X-Version", strconv.FormatInt(int64(user.Version), 32))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user, "permissions": permissions}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateUserActivationHandler deactivates or reactivates a user. A
+// deactivated user loses every session, API key and calendar feed straight
+// away, and stays locked out until reactivated here.
+func (app *application) updateUserActivationHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.readUserParam(w, r)
+	if !ok {
+		return
+	}
+
+	if ok := app.checkVersion(r, user.Version); !ok {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Activated *bool `json:"activated"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if v.Check(input.Activated != nil, "activated", "must be provided"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Users.SetDeactivated(user, !*input.Activated)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !user.Activated {
+		err = app.revokeAllCredentials(user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	app.logAdminAction(r, "update user activation", user)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// forcePasswordResetHandler replaces the user's password with a random one,
+// signs them out everywhere and emails them a password reset token.
+func (app *application) forcePasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.readUserParam(w, r)
+	if !ok {
+		return
+	}
+
+	randomBytes := make([]byte, 32)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = user.Password.Set(base64.RawURLEncoding.EncodeToString(randomBytes), app.config.password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Users.UpdateUser(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.revokeAllCredentials(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	token, err := app.models.Tokens.New(user.ID, time.Hour*3, data.ScopePasswordReset)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.background(func() {
+		data := map[string]interface{}{
+			"resetPasswordToken": token.Plaintext,
+		}
+
+		err := app.mailer.Send(user.Email, "reset_password.tmpl", data)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{
+				"email": user.Email,
+			})
+		}
+	})
+
+	app.logAdminAction(r, "force password reset", user)
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"message": "password reset email sent to the user"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) revokeUserTokensHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.readUserParam(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.revokeAllCredentials(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.logAdminAction(r, "revoke user tokens", user)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "all tokens, api keys and calendar feeds of the user revoked"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// revokeAllCredentials deletes every token, API key and calendar feed of the
+// user. Stateless access tokens already issued stay valid until they expire.
+func (app *application) revokeAllCredentials(userID int64) error {
+	err := app.models.Tokens.ClearAllForUser(userID)
+	if err != nil {
+		return err
+	}
+
+	err = app.models.APIKeys.DeleteAllForUser(userID)
+	if err != nil {
+		return err
+	}
+
+	return app.models.CalendarFeeds.Delete(userID)
+}
+
+// readUserParam loads the user named by the id parameter, responding with a
+// not found or server error if it can't. Users outside the admin's
+// organization are not found.
+func (app *application) readUserParam(w http.ResponseWriter, r *http.Request) (*data.User, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	user, err := app.models.Users.Get(int64(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	if admin := app.contextGetUser(r); admin.OrganizationID != nil {
+		if user.OrganizationID == nil || *user.OrganizationID != *admin.OrganizationID {
+			app.notFoundResponse(w, r)
+			return nil, false
+		}
+	}
+
+	return user, true
+}
+
+func (app *application) logAdminAction(r *http.Request, action string, target *data.User) {
+	app.logger.PrintInfo(action, map[string]string{
+		"admin_id": strconv.FormatInt(app.contextGetUser(r).ID, 10),
+		"user_id":  strconv.FormatInt(target.ID, 10),
+	})
+}
+
+const impersonationTokenTTL = 30 * time.Minute
+
+func (app *application) createImpersonationHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.readUserParam(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Reason   string `json:"reason"`
+		Elevated bool   `json:"elevated"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	session := &data.ImpersonationSession{
+		AdminID:  app.contextGetUser(r).ID,
+		UserID:   user.ID,
+		Reason:   input.Reason,
+		Elevated: input.Elevated,
+	}
+
+	v := validator.New()
+	if data.ValidateImpersonationSession(v, session); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Impersonations.New(session, impersonationTokenTTL)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.logger.PrintInfo("impersonation started", map[string]string{
+		"admin_id":              strconv.FormatInt(session.AdminID, 10),
+		"user_id":               strconv.FormatInt(session.UserID, 10),
+		"impersonation_session": strconv.FormatInt(session.ID, 10),
+		"elevated":              strconv.FormatBool(session.Elevated),
+		"reason":                session.Reason,
+	})
+
+	env := envelope{"impersonation_token": session.Token, "impersonation": session}
+
+	err = app.writeJSON(w, http.StatusCreated, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listImpersonationAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	var input struct {
+		SessionID int
+		data.Filters
+	}
+
+	input.SessionID = app.readInt(&qs, "session_id", 0, v)
+
+	input.Page = app.readInt(&qs, "page", 1, v)
+	input.PageSize = app.readInt(&qs, "page_size", 20, v)
+	input.Sort = app.readString(&qs, "sort", "-created_at")
+
+	input.SortSafelist = []string{"created_at", "-created_at"}
+
+	if data.ValidateFilter(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	entries, metadata, err := app.models.Impersonations.GetAuditLog(int64(input.SessionID), app.contextGetUser(r).OrganizationID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"audit_log": entries, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
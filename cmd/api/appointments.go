@@ -0,0 +1,423 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/data"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+)
+
+// slotStep is how far apart offered slots start.
+const slotStep = 30 * time.Minute
+
+func (app *application) listServiceBaysHandler(w http.ResponseWriter, r *http.Request) {
+	bays, err := app.models.ServiceBays.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"service_bays": bays}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createServiceBayHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string `json:"name"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	bay := &data.ServiceBay{Name: input.Name}
+
+	v := validator.New()
+	if data.ValidateServiceBay(v, bay); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.ServiceBays.Insert(bay)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateBayName):
+			v.AddError("name", "a service bay with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"service_bay": bay}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteServiceBayHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.ServiceBays.Delete(int64(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "service bay successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showAvailabilityHandler lists the free slots of every service bay on a day
+// for an appointment of the given length. Slots fall within the business
+// hours of the caller's organization, in its time zone, and there are none on
+// closed days.
+func (app *application) showAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	schedule, err := app.scheduleFor(app.contextGetUser(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	date, err := time.ParseInLocation(time.DateOnly, app.readString(&qs, "date", ""), schedule.Location)
+	if err != nil {
+		v.AddError("date", "must be a date in the YYYY-MM-DD format")
+	}
+
+	minutes := app.readInt(&qs, "duration_minutes", 60, v)
+	v.Check(validator.Min(minutes, 1), "duration_minutes", "must be greater than zero")
+	v.Check(validator.Max(minutes, 12*60), "duration_minutes", "must not be more than 12 hours")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	opens, closes, open := schedule.Hours(date)
+
+	bays, err := app.models.ServiceBays.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	appointments, err := app.models.Appointments.GetAllBetween(opens, closes)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	booked := make(map[int64][]*data.Appointment)
+	for _, a := range appointments {
+		booked[a.ServiceBayID] = append(booked[a.ServiceBayID], a)
+	}
+
+	availability := make([]envelope, 0, len(bays))
+
+	for _, bay := range bays {
+		slots := []data.Slot{}
+		if open {
+			slots = data.FreeSlots(opens, closes, time.Duration(minutes)*time.Minute, slotStep, booked[bay.ID])
+		}
+
+		availability = append(availability, envelope{
+			"service_bay": bay,
+			"slots":       slots,
+		})
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"availability": availability}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createAppointmentHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		VehicleID       int64     `json:"vehicle_id"`
+		ServiceBayID    int64     `json:"service_bay_id"`
+		FollowupID      *int64    `json:"followup_id"`
+		StartsAt        time.Time `json:"starts_at"`
+		DurationMinutes int       `json:"duration_minutes"`
+		Notes           string    `json:"notes"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	appointment := &data.Appointment{
+		VehicleID:    input.VehicleID,
+		ServiceBayID: input.ServiceBayID,
+		FollowupID:   input.FollowupID,
+		StartsAt:     input.StartsAt,
+		EndsAt:       input.StartsAt.Add(time.Duration(input.DurationMinutes) * time.Minute),
+		Notes:        input.Notes,
+	}
+
+	v := validator.New()
+	if data.ValidateAppointment(v, appointment); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if ok := app.checkBusinessHours(w, r, appointment); !ok {
+		return
+	}
+
+	err = app.models.Appointments.Insert(appointment)
+	if err != nil {
+		app.appointmentErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/appointments/%d", appointment.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"appointment": appointment}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showAppointmentHandler(w http.ResponseWriter, r *http.Request) {
+	appointment, ok := app.readAppointmentParam(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"appointment": appointment}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateAppointmentHandler moves or edits an appointment. A followup_id of 0
+// unlinks it from its follow-up.
+func (app *application) updateAppointmentHandler(w http.ResponseWriter, r *http.Request) {
+	appointment, ok := app.readAppointmentParam(w, r)
+	if !ok {
+		return
+	}
+
+	if ok := app.checkVersion(r, appointment.Version); !ok {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	var input struct {
+		VehicleID       *int64     `json:"vehicle_id"`
+		ServiceBayID    *int64     `json:"service_bay_id"`
+		FollowupID      *int64     `json:"followup_id"`
+		StartsAt        *time.Time `json:"starts_at"`
+		DurationMinutes *int       `json:"duration_minutes"`
+		Notes           *string    `json:"notes"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	length := appointment.EndsAt.Sub(appointment.StartsAt)
+
+	if input.VehicleID != nil {
+		appointment.VehicleID = *input.VehicleID
+	}
+	if input.ServiceBayID != nil {
+		appointment.ServiceBayID = *input.ServiceBayID
+	}
+	if input.FollowupID != nil {
+		appointment.FollowupID = input.FollowupID
+		if *input.FollowupID == 0 {
+			appointment.FollowupID = nil
+		}
+	}
+	if input.StartsAt != nil {
+		appointment.StartsAt = *input.StartsAt
+	}
+	if input.DurationMinutes != nil {
+		length = time.Duration(*input.DurationMinutes) * time.Minute
+	}
+	if input.Notes != nil {
+		appointment.Notes = *input.Notes
+	}
+
+	appointment.EndsAt = appointment.StartsAt.Add(length)
+
+	v := validator.New()
+	if data.ValidateAppointment(v, appointment); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if ok := app.checkBusinessHours(w, r, appointment); !ok {
+		return
+	}
+
+	err = app.models.Appointments.Update(appointment)
+	if err != nil {
+		app.appointmentErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"appointment": appointment}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteAppointmentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Appointments.Delete(int64(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "appointment successfully cancelled"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listAppointmentsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	var input data.AppointmentFilters
+
+	input.From = app.readTime(&qs, "from", v)
+	input.To = app.readTime(&qs, "to", v)
+	input.ServiceBayID = int64(app.readInt(&qs, "service_bay_id", 0, v))
+	input.FollowupID = int64(app.readInt(&qs, "followup_id", 0, v))
+	input.VehicleID = int64(app.readInt(&qs, "vehicle_id", 0, v))
+
+	input.Page = app.readInt(&qs, "page", 1, v)
+	input.PageSize = app.readInt(&qs, "page_size", 20, v)
+	input.Sort = app.readString(&qs, "sort", "starts_at")
+
+	input.SortSafelist = []string{"id", "starts_at", "created_at", "-id", "-starts_at", "-created_at"}
+
+	if data.ValidateFilter(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	appointments, metadata, err := app.models.Appointments.GetAll(input)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"appointments": appointments, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// checkBusinessHours responds with a validation error, and returns false, if
+// the appointment doesn't fit within one business day of the caller's
+// organization.
+func (app *application) checkBusinessHours(w http.ResponseWriter, r *http.Request, appointment *data.Appointment) bool {
+	schedule, err := app.scheduleFor(app.contextGetUser(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+
+	opens, closes, open := schedule.Hours(appointment.StartsAt)
+
+	v := validator.New()
+	v.Check(open && !appointment.StartsAt.Before(opens) && !appointment.EndsAt.After(closes), "starts_at", "must be within business hours")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return false
+	}
+
+	return true
+}
+
+// appointmentErrorResponse responds to an error from inserting or updating an
+// appointment.
+func (app *application) appointmentErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	v := validator.New()
+
+	switch {
+	case errors.Is(err, data.ErrBayUnavailable):
+		v.AddError("starts_at", "the service bay is already booked at this time")
+		app.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, data.ErrUnknownVehicle):
+		v.AddError("vehicle_id", "must refer to an existing vehicle")
+		app.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, data.ErrUnknownServiceBay):
+		v.AddError("service_bay_id", "must refer to an existing service bay")
+		app.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, data.ErrUnknownFollowup):
+		v.AddError("followup_id", "must refer to an existing followup")
+		app.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, data.ErrEditConflict):
+		app.editConflictResponse(w, r)
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readAppointmentParam loads the appointment named by the id parameter,
+// responding with a not found or server error if it can't.
+func (app *application) readAppointmentParam(w http.ResponseWriter, r *http.Request) (*data.Appointment, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	appointment, err := app.models.Appointments.Get(int64(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return appointment, true
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/data"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/imaging"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/storage"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// createAttachmentHandler uploads a file, sent as the "file" field of a
+// multipart form. The type is sniffed from the content rather than trusted
+// from the client. Uploading a file the vehicle already has returns the
+// existing attachment. Thumbnails of photos are made in the background.
+func (app *application) createAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	vehicleID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// Leave some room for the rest of the form, the size of the file itself
+	// is checked by validation.
+	r.Body = http.MaxBytesReader(w, r.Body, app.config.attachments.maxSize+64<<10)
+
+	err = r.ParseMultipartForm(1 << 20)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &maxBytesError):
+			v := validator.New()
+			v.AddError("file", fmt.Sprintf("must not be larger than %d bytes", app.config.attachments.maxSize))
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.badRequestResponse(w, r, errors.New("body must be a multipart form"))
+		}
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		v := validator.New()
+		v.AddError("file", "must be provided")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	defer file.Close()
+
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	attachment := &data.Attachment{
+		VehicleID:   int64(vehicleID),
+		UploadedBy:  &user.ID,
+		Filename:    cleanFilename(header.Filename),
+		ContentType: strings.TrimSuffix(http.DetectContentType(sniff[:n]), "; charset=utf-8"),
+		Size:        header.Size,
+	}
+
+	v := validator.New()
+	if data.ValidateAttachment(v, attachment, app.config.attachments.maxSize); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var content io.ReadSeeker = file
+
+	// Phones record where photos were taken, which must not leak through
+	// the files we hand out.
+	if strings.HasPrefix(attachment.ContentType, "image/") {
+		b, err := io.ReadAll(file)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		b, err = imaging.StripLocation(b)
+		if err != nil {
+			v.AddError("file", "must be a valid image")
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		content = bytes.NewReader(b)
+		attachment.Size = int64(len(b))
+	}
+
+	hash := sha256.New()
+	_, err = content.Seek(0, io.SeekStart)
+	if err == nil {
+		_, err = io.Copy(hash, content)
+	}
+	if err == nil {
+		_, err = content.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	attachment.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	existing, err := app.models.Attachments.GetForHash(attachment.VehicleID, attachment.SHA256)
+	switch {
+	case err == nil:
+		app.writeAttachment(w, r, http.StatusOK, existing)
+		return
+	case !errors.Is(err, data.ErrNoRecordFound):
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// The same file may already be stored for another vehicle. Claiming it
+	// first keeps the blob collector from removing it before the attachment
+	// refers to it.
+	err = app.models.Attachments.ClaimBlob(attachment.SHA256)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	stored, err := app.storage.Exists(r.Context(), attachment.SHA256)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !stored {
+		err = app.storage.Put(r.Context(), attachment.SHA256, content, attachment.Size, attachment.ContentType)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.models.Attachments.Insert(attachment)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrUnknownVehicle):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateAttachment):
+			existing, err = app.models.Attachments.GetForHash(attachment.VehicleID, attachment.SHA256)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+			app.writeAttachment(w, r, http.StatusOK, existing)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if imaging.Thumbnailable(attachment.ContentType) {
+		app.background(func() {
+			err := app.makeThumbnails(attachment.SHA256)
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{
+					"attachment_id": strconv.FormatInt(attachment.ID, 10),
+				})
+			}
+		})
+	}
+
+	app.writeAttachment(w, r, http.StatusCreated, attachment)
+}
+
+func (app *application) showAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	attachment, ok := app.readAttachmentParam(w, r)
+	if !ok {
+		return
+	}
+
+	app.writeAttachment(w, r, http.StatusOK, attachment)
+}
+
+// deleteAttachmentHandler removes the attachment. The stored file and its
+// thumbnails are removed by the blob collector once no other attachment
+// shares them.
+func (app *application) deleteAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	attachment, ok := app.readAttachmentParam(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.Attachments.Delete(attachment.ID, attachment.VehicleID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "attachment successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listAttachmentsHandler(w http.ResponseWriter, r *http.Request) {
+	vehicleID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	qs := r.URL.Query()
+	v := validator.New()
+
+	var input data.AttachmentFilters
+
+	input.VehicleID = int64(vehicleID)
+	input.ContentType = app.readString(&qs, "content_type", "")
+
+	input.Page = app.readInt(&qs, "page", 1, v)
+	input.PageSize = app.readInt(&qs, "page_size", 20, v)
+	input.Sort = app.readString(&qs, "sort", "-created_at")
+
+	input.SortSafelist = []string{"id", "filename", "size", "created_at", "-id", "-filename", "-size", "-created_at"}
+
+	if data.ValidateFilter(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	attachments, metadata, err := app.models.Attachments.GetAll(input)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	for _, attachment := range attachments {
+		err = app.signAttachment(attachment)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"attachments": attachments, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showFileHandler serves files of the local storage through the signed links
+// it hands out. The signature is the only authorization, so links may be
+// used by browsers and image tags directly.
+func (app *application) showFileHandler(w http.ResponseWriter, r *http.Request) {
+	local, ok := app.storage.(*storage.Local)
+	if !ok {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	key := httprouter.ParamsFromContext(r.Context()).ByName("key")
+
+	filename, err := local.Verify(key, r.URL.Query())
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	object, err := local.Get(r.Context(), key)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer object.Close()
+
+	file, ok := object.(io.ReadSeeker)
+	if !ok {
+		app.serverErrorResponse(w, r, errors.New("local storage object is not seekable"))
+		return
+	}
+
+	w.Header().Set("Content-Disposition", storage.ContentDisposition(filename))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+
+	http.ServeContent(w, r, filename, time.Time{}, file)
+}
+
+// cleanFilename drops any directories from an uploaded file's name, which
+// some browsers send with Windows separators.
+func cleanFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	if name == "." || name == "/" {
+		return ""
+	}
+
+	return name
+}
+
+func (app *application) writeAttachment(w http.ResponseWriter, r *http.Request, status int, attachment *data.Attachment) {
+	err := app.signAttachment(attachment)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, status, envelope{"attachment": attachment}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// signAttachment sets the attachment's time-limited download link.
+func (app *application) signAttachment(attachment *data.Attachment) error {
+	expiry := time.Now().Add(app.config.attachments.urlTTL)
+
+	url, err := app.storage.SignedURL(attachment.SHA256, attachment.Filename, app.config.attachments.urlTTL)
+	if err != nil {
+		return err
+	}
+
+	attachment.URL = url
+	attachment.URLExpiry = &expiry
+
+	return nil
+}
+
+// readAttachmentParam loads the attachment named by the attachment_id
+// parameter of the vehicle named by the id parameter, responding with a not
+// found or server error if it can't.
+func (app *application) readAttachmentParam(w http.ResponseWriter, r *http.Request) (*data.Attachment, bool) {
+	vehicleID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	id, err := app.readNamedIDParam(r, "attachment_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	attachment, err := app.models.Attachments.Get(id, int64(vehicleID))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return attachment, true
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/data"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/ical"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	// calendarFeedHistory is how far back the feed goes, so that recently
+	// closed follow-ups still show up and cancellations reach calendars.
+	calendarFeedHistory = 30 * 24 * time.Hour
+
+	calendarEventDuration = 30 * time.Minute
+	calendarAlarmBefore   = 15 * time.Minute
+)
+
+// showCalendarFeedHandler serves the follow-ups assigned to the feed's owner,
+// and the appointments booked for them, as an iCalendar document. The token
+// in the path is the only credential.
+func (app *application) showCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	token := httprouter.ParamsFromContext(r.Context()).ByName("token")
+
+	v := validator.New()
+	if data.ValidatePlaintextToken(v, token); !v.Valid() {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	userID, err := app.models.CalendarFeeds.GetUserIDForToken(token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	now := time.Now()
+
+	followups, err := app.models.Followups.GetAllAssigned(userID, now.Add(-calendarFeedHistory))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	appointments, err := app.models.Appointments.GetAllForAssignee(userID, now.Add(-calendarFeedHistory))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	bays, err := app.models.ServiceBays.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	bayNames := make(map[int64]string, len(bays))
+	for _, bay := range bays {
+		bayNames[bay.ID] = bay.Name
+	}
+
+	calendar := &ical.Calendar{
+		ProdID: "-//FollowUps//FollowUps API//EN",
+		Name:   "FollowUps",
+	}
+
+	for _, followup := range followups {
+		calendar.Events = append(calendar.Events, followupEvent(followup, now))
+	}
+
+	for _, appointment := range appointments {
+		calendar.Events = append(calendar.Events, appointmentEvent(appointment, bayNames[appointment.ServiceBayID], now))
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+
+	_, err = calendar.WriteTo(w)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+func followupEvent(followup *data.Followup, now time.Time) ical.Event {
+	event := ical.Event{
+		UID:         fmt.Sprintf("followup-%d@followups", followup.ID),
+		Sequence:    followup.Version,
+		Stamp:       now,
+		Start:       followup.DueAt,
+		Duration:    calendarEventDuration,
+		Summary:     followup.Title,
+		Description: followup.Notes,
+		Status:      ical.StatusConfirmed,
+	}
+
+	switch followup.Status {
+	case data.FollowupLost, data.FollowupCancelled:
+		event.Status = ical.StatusCancelled
+	case data.FollowupCompleted:
+	default:
+		event.Alarms = []ical.Alarm{{Before: calendarAlarmBefore, Description: followup.Title}}
+	}
+
+	return event
+}
+
+func appointmentEvent(appointment *data.Appointment, bayName string, now time.Time) ical.Event {
+	summary := "Workshop appointment"
+	if bayName != "" {
+		summary += " in " + bayName
+	}
+
+	return ical.Event{
+		UID:         fmt.Sprintf("appointment-%d@followups", appointment.ID),
+		Sequence:    appointment.Version,
+		Stamp:       now,
+		Start:       appointment.StartsAt,
+		Duration:    appointment.EndsAt.Sub(appointment.StartsAt),
+		Summary:     summary,
+		Description: appointment.Notes,
+		Location:    bayName,
+		Status:      ical.StatusConfirmed,
+		Alarms:      []ical.Alarm{{Before: calendarAlarmBefore, Description: summary}},
+	}
+}
+
+// rotateCalendarFeedHandler issues a new feed URL for the user. Any URL issued
+// before stops working.
+func (app *application) rotateCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := app.models.CalendarFeeds.Rotate(app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	feed := envelope{
+		"token": token.Plaintext,
+		"path":  "/v1/feeds/calendar/" + token.Plaintext,
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"calendar_feed": feed}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.models.CalendarFeeds.Delete(app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "calendar feed successfully disabled"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
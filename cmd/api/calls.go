@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/data"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+)
+
+// createCallLogHandler logs a call made for the follow-up. A call back later
+// outcome also moves the follow-up's due date to the time agreed with the
+// customer, or the next business moment after it.
+func (app *application) createCallLogHandler(w http.ResponseWriter, r *http.Request) {
+	followup, ok := app.readFollowupParam(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Direction  string     `json:"direction"`
+		Number     string     `json:"number"`
+		Duration   int        `json:"duration"`
+		Outcome    string     `json:"outcome"`
+		NextCallAt *time.Time `json:"next_call_at"`
+		Notes      string     `json:"notes"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	call := &data.CallLog{
+		FollowupID: followup.ID,
+		VehicleID:  followup.VehicleID,
+		AdvisorID:  &user.ID,
+		Direction:  input.Direction,
+		Number:     input.Number,
+		Duration:   input.Duration,
+		Outcome:    input.Outcome,
+		NextCallAt: input.NextCallAt,
+		Notes:      input.Notes,
+	}
+
+	if call.Direction == "" {
+		call.Direction = data.CallOutbound
+	}
+
+	v := validator.New()
+	if data.ValidateCallLog(v, call, followup); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var reschedule *data.Followup
+
+	if call.Outcome == data.CallBackLater {
+		schedule, err := app.scheduleFor(user)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		followup.DueAt = schedule.NextBusinessMoment(*call.NextCallAt)
+		reschedule = followup
+	}
+
+	err = app.models.Calls.Insert(call, reschedule)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrUnknownFollowup):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"call": call}
+	if reschedule != nil {
+		env["followup"] = reschedule
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listCallLogsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	qs := r.URL.Query()
+	v := validator.New()
+
+	var input data.Filters
+
+	input.Page = app.readInt(&qs, "page", 1, v)
+	input.PageSize = app.readInt(&qs, "page_size", 20, v)
+	input.Sort = app.readString(&qs, "sort", "-created_at")
+
+	input.SortSafelist = []string{"id", "created_at", "duration", "-id", "-created_at", "-duration"}
+
+	if data.ValidateFilter(v, input); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	calls, metadata, err := app.models.Calls.GetAllForFollowup(int64(id), input)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"calls": calls, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showCallStatsHandler sums up the calls of each advisor in the caller's
+// organization between from and to, the last 30 days by default.
+func (app *application) showCallStatsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	user := app.contextGetUser(r)
+
+	input := data.CallStatsFilters{
+		UserID: user.ID,
+		To:     time.Now(),
+	}
+
+	if user.OrganizationID != nil {
+		input.OrganizationID = *user.OrganizationID
+	}
+
+	if to := app.readTime(&qs, "to", v); to != nil {
+		input.To = *to
+	}
+
+	input.From = input.To.AddDate(0, 0, -30)
+	if from := app.readTime(&qs, "from", v); from != nil {
+		input.From = *from
+	}
+
+	input.AdvisorID = int64(app.readInt(&qs, "advisor_id", 0, v))
+
+	v.Check(input.From.Before(input.To), "from", "must be before to")
+	v.Check(input.To.Sub(input.From) <= 366*24*time.Hour, "from", "must be at most a year before to")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	stats, err := app.models.Calls.GetStats(input)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"stats": stats, "from": input.From, "to": input.To}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/data"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+)
+
+// Comment handlers serve both /v1/vehicles/:id/comments and
+// /v1/followups/:id/comments, see readCommentThread.
+
+func (app *application) listCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	thread, err := app.readCommentThread(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	qs := r.URL.Query()
+	v := validator.New()
+
+	var input data.Filters
+
+	input.Page = app.readInt(&qs, "page", 1, v)
+	input.PageSize = app.readInt(&qs, "page_size", 20, v)
+	input.Sort = app.readString(&qs, "sort", "created_at")
+
+	input.SortSafelist = []string{"id", "created_at", "-id", "-created_at"}
+
+	if data.ValidateFilter(v, input); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	comments, metadata, err := app.models.Comments.GetAll(thread, input)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"comments": comments, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createCommentHandler posts a comment. Users mentioned in it are notified
+// by email.
+func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Request) {
+	thread, err := app.readCommentThread(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Body string `json:"body"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	comment := &data.Comment{
+		AuthorID:   &user.ID,
+		AuthorName: user.Name,
+		Body:       input.Body,
+	}
+
+	if thread.VehicleID != 0 {
+		comment.VehicleID = &thread.VehicleID
+	} else {
+		comment.FollowupID = &thread.FollowupID
+	}
+
+	v := validator.New()
+	data.ValidateComment(v, comment)
+
+	mentioned, err := app.resolveMentions(v, user, comment)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Comments.Insert(comment)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrUnknownVehicle), errors.Is(err, data.ErrUnknownFollowup):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.notifyMentions(r, comment, mentioned)
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"comment": comment}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateCommentHandler lets the author edit a comment for a short while after
+// posting it. Only users newly mentioned by the edit are notified.
+func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.readCommentParam(w, r)
+	if !ok {
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	if comment.AuthorID == nil || *comment.AuthorID != user.ID {
+		app.notCommentAuthorResponse(w, r)
+		return
+	}
+
+	if time.Since(comment.CreatedAt) > app.config.comments.editWindow {
+		app.editWindowClosedResponse(w, r)
+		return
+	}
+
+	if ok := app.checkVersion(r, comment.Version); !ok {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Body *string `json:"body"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Body != nil {
+		comment.Body = *input.Body
+	}
+
+	previous := make(map[int64]bool)
+	for _, id := range comment.Mentions {
+		previous[id] = true
+	}
+
+	v := validator.New()
+	data.ValidateComment(v, comment)
+
+	mentioned, err := app.resolveMentions(v, user, comment)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Comments.Update(comment)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	added := make([]*data.User, 0, len(mentioned))
+	for _, u := range mentioned {
+		if !previous[u.ID] {
+			added = append(added, u)
+		}
+	}
+
+	app.notifyMentions(r, comment, added)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"comment": comment}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.readCommentParam(w, r)
+	if !ok {
+		return
+	}
+
+	if comment.AuthorID == nil || *comment.AuthorID != app.contextGetUser(r).ID {
+		app.notCommentAuthorResponse(w, r)
+		return
+	}
+
+	err := app.models.Comments.Delete(comment.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "comment successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// resolveMentions looks up the users mentioned in the comment and records
+// their ids on it. Only members of the author's organization can be
+// mentioned, anyone else is reported as a validation error.
+func (app *application) resolveMentions(v *validator.Validator, user *data.User, comment *data.Comment) ([]*data.User, error) {
+	emails := comment.MentionedEmails()
+	comment.Mentions = []int64{}
+
+	if len(emails) == 0 {
+		return nil, nil
+	}
+
+	if user.OrganizationID == nil {
+		v.AddError("body", "can only mention members of your organization")
+		return nil, nil
+	}
+
+	users, err := app.models.Users.GetAllInOrganization(*user.OrganizationID, emails)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool)
+	for _, u := range users {
+		found[strings.ToLower(u.Email)] = true
+		comment.Mentions = append(comment.Mentions, u.ID)
+	}
+
+	for _, email := range emails {
+		if !found[email] {
+			v.AddError("body", fmt.Sprintf("mentions %s who is not a member of your organization", email))
+			break
+		}
+	}
+
+	return users, nil
+}
+
+// notifyMentions emails the mentioned users, other than the author, about
+// the comment.
+func (app *application) notifyMentions(r *http.Request, comment *data.Comment, mentioned []*data.User) {
+	author := app.contextGetUser(r)
+
+	var thread, path string
+
+	switch {
+	case comment.VehicleID != nil:
+		thread = fmt.Sprintf("vehicle %d", *comment.VehicleID)
+		path = fmt.Sprintf("/v1/vehicles/%d/comments", *comment.VehicleID)
+	default:
+		thread = fmt.Sprintf("follow-up %d", *comment.FollowupID)
+		path = fmt.Sprintf("/v1/followups/%d/comments", *comment.FollowupID)
+	}
+
+	for _, user := range mentioned {
+		if user.ID == author.ID {
+			continue
+		}
+
+		user := user
+
+		app.background(func() {
+			data := map[string]any{
+				"name":   user.Name,
+				"author": author.Name,
+				"thread": thread,
+				"body":   comment.Body,
+				"path":   path,
+			}
+
+			err := app.mailer.Send(user.Email, "comment_mention.tmpl", data)
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{
+					"email":      user.Email,
+					"comment_id": strconv.FormatInt(comment.ID, 10),
+				})
+			}
+		})
+	}
+}
+
+// readCommentThread reads the vehicle or follow-up that a comment route is
+// about from its path.
+func (app *application) readCommentThread(r *http.Request) (data.CommentThread, error) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		return data.CommentThread{}, err
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/v1/followups/") {
+		return data.CommentThread{FollowupID: int64(id)}, nil
+	}
+
+	return data.CommentThread{VehicleID: int64(id)}, nil
+}
+
+// readCommentParam loads the comment named by the comment_id parameter from
+// the thread of the route, responding with a not found or server error if it
+// can't.
+func (app *application) readCommentParam(w http.ResponseWriter, r *http.Request) (*data.Comment, bool) {
+	thread, err := app.readCommentThread(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	id, err := app.readNamedIDParam(r, "comment_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	comment, err := app.models.Comments.Get(id, thread)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return comment, true
+}
@@ -9,7 +9,11 @@ import (
 
 type contextKey string
 
-const userContextKey = contextKey("user")
+const (
+	userContextKey          = contextKey("user")
+	permissionsContextKey   = contextKey("permissions")
+	impersonationContextKey = contextKey("impersonation")
+)
 
 func (app *application) contextSetUser(r *http.Request, user *data.User) *http.Request {
 	ctx := context.WithValue(r.Context(), userContextKey, user)
@@ -24,3 +28,42 @@ func (app *application) contextGetUser(r *http.Request) *data.User {
 
 	return user
 }
+
+// contextSetPermissions stores permissions that were already resolved while
+// authenticating, so requirePermission can skip the database lookup.
+func (app *application) contextSetPermissions(r *http.Request, permissions data.Permissions) *http.Request {
+	ctx := context.WithValue(r.Context(), permissionsContextKey, permissions)
+	return r.WithContext(ctx)
+}
+
+func (app *application) contextGetPermissions(r *http.Request) (data.Permissions, bool) {
+	permissions, ok := r.Context().Value(permissionsContextKey).(data.Permissions)
+	return permissions, ok
+}
+
+// userPermissions returns the permissions of the request user, preferring the
+// ones resolved by authenticate over a database lookup.
+func (app *application) userPermissions(r *http.Request) (data.Permissions, error) {
+	if permissions, ok := app.contextGetPermissions(r); ok {
+		return permissions, nil
+	}
+
+	return app.models.Permissions.GetAllForUser(app.contextGetUser(r).ID)
+}
+
+// impersonation is set on requests made with an impersonation token. The
+// request user is the impersonated user, admin is who is acting as them.
+type impersonation struct {
+	session *data.ImpersonationSession
+	admin   *data.User
+}
+
+func (app *application) contextSetImpersonation(r *http.Request, imp *impersonation) *http.Request {
+	ctx := context.WithValue(r.Context(), impersonationContextKey, imp)
+	return r.WithContext(ctx)
+}
+
+func (app *application) contextGetImpersonation(r *http.Request) *impersonation {
+	imp, _ := r.Context().Value(impersonationContextKey).(*impersonation)
+	return imp
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/data"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+)
+
+func (app *application) createVehicleDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	vehicleID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Type       string `json:"type"`
+		Number     string `json:"number"`
+		Issuer     string `json:"issuer"`
+		ValidFrom  string `json:"valid_from"`
+		ValidUntil string `json:"valid_until"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	doc := &data.VehicleDocument{
+		VehicleID:  int64(vehicleID),
+		Type:       input.Type,
+		Number:     input.Number,
+		Issuer:     input.Issuer,
+		ValidFrom:  input.ValidFrom,
+		ValidUntil: input.ValidUntil,
+	}
+
+	v := validator.New()
+	if data.ValidateVehicleDocument(v, doc); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	followup, err := app.renewalFollowup(r, doc)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Documents.Insert(doc, followup)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrUnknownVehicle):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"document": doc, "followup": followup}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showVehicleDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	doc, ok := app.readVehicleDocumentParam(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"document": doc}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateVehicleDocumentHandler edits a document. Renewing it, by moving
+// valid_until, creates a new renewal follow-up for the new expiry date.
+func (app *application) updateVehicleDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	doc, ok := app.readVehicleDocumentParam(w, r)
+	if !ok {
+		return
+	}
+
+	if ok := app.checkVersion(r, doc.Version); !ok {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Type       *string `json:"type"`
+		Number     *string `json:"number"`
+		Issuer     *string `json:"issuer"`
+		ValidFrom  *string `json:"valid_from"`
+		ValidUntil *string `json:"valid_until"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	validUntil := doc.ValidUntil
+
+	if input.Type != nil {
+		doc.Type = *input.Type
+	}
+	if input.Number != nil {
+		doc.Number = *input.Number
+	}
+	if input.Issuer != nil {
+		doc.Issuer = *input.Issuer
+	}
+	if input.ValidFrom != nil {
+		doc.ValidFrom = *input.ValidFrom
+	}
+	if input.ValidUntil != nil {
+		doc.ValidUntil = *input.ValidUntil
+	}
+
+	v := validator.New()
+	if data.ValidateVehicleDocument(v, doc); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var followup *data.Followup
+
+	if doc.ValidUntil != validUntil {
+		followup, err = app.renewalFollowup(r, doc)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.models.Documents.Update(doc, followup, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"document": doc}
+	if followup != nil {
+		env["followup"] = followup
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteVehicleDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	vehicleID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	id, err := app.readNamedIDParam(r, "document_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Documents.Delete(id, int64(vehicleID), app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "document successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listVehicleDocumentsHandler lists the documents of one vehicle.
+func (app *application) listVehicleDocumentsHandler(w http.ResponseWriter, r *http.Request) {
+	vehicleID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	app.listDocuments(w, r, int64(vehicleID))
+}
+
+// listDocumentsHandler lists documents across all vehicles, typically with
+// expiring_within to find the ones due for renewal.
+func (app *application) listDocumentsHandler(w http.ResponseWriter, r *http.Request) {
+	app.listDocuments(w, r, 0)
+}
+
+func (app *application) listDocuments(w http.ResponseWriter, r *http.Request, vehicleID int64) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	var input data.DocumentFilters
+
+	input.VehicleID = vehicleID
+	input.Type = app.readString(&qs, "type", "")
+
+	if qs.Has("expiring_within") {
+		days := app.readInt(&qs, "expiring_within", 0, v)
+		v.Check(validator.Min(days, 0), "expiring_within", "must be greater than or equal to 0")
+		v.Check(validator.Max(days, 3650), "expiring_within", "must be less than or equal to 3650")
+		input.ExpiringWithin = &days
+	}
+
+	input.Page = app.readInt(&qs, "page", 1, v)
+	input.PageSize = app.readInt(&qs, "page_size", 20, v)
+	input.Sort = app.readString(&qs, "sort", "valid_until")
+
+	input.SortSafelist = []string{"id", "type", "valid_until", "created_at", "-id", "-type", "-valid_until", "-created_at"}
+
+	if data.ValidateFilter(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	docs, metadata, err := app.models.Documents.GetAll(input)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"documents": docs, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// renewalFollowup builds the follow-up to renew the document, scheduled by
+// the caller's organization.
+func (app *application) renewalFollowup(r *http.Request, doc *data.VehicleDocument) (*data.Followup, error) {
+	schedule, err := app.scheduleFor(app.contextGetUser(r))
+	if err != nil {
+		return nil, err
+	}
+
+	return doc.RenewalFollowup(app.config.documents.followupLead, schedule)
+}
+
+// readVehicleDocumentParam loads the document named by the document_id
+// parameter of the vehicle named by the id parameter, responding with a not
+// found or server error if it can't.
+func (app *application) readVehicleDocumentParam(w http.ResponseWriter, r *http.Request) (*data.VehicleDocument, bool) {
+	vehicleID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	id, err := app.readNamedIDParam(r, "document_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	doc, err := app.models.Documents.Get(id, int64(vehicleID))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return doc, true
+}
@@ -84,7 +84,37 @@ func (app *application) inactiveAccountResponse(w http.ResponseWriter, r *http.R
 	app.errorResponse(w, r, http.StatusForbidden, message)
 }
 
+func (app *application) deactivatedAccountResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account has been deactivated"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) noOrganizationResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account must belong to an organization to access this resource"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
 func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
 	message := "your user account doesn't have the necessary permissions to access this resource"
 	app.errorResponse(w, r, http.StatusForbidden, message)
 }
+
+func (app *application) readOnlyImpersonationResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this impersonation session is read-only"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) impersonationNotPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this action is not available while impersonating a user"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) notCommentAuthorResponse(w http.ResponseWriter, r *http.Request) {
+	message := "only the author of a comment can change it"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) editWindowClosedResponse(w http.ResponseWriter, r *http.Request) {
+	message := fmt.Sprintf("comments can only be edited within %s of being posted", app.config.comments.editWindow)
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/data"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+)
+
+const (
+	permissionFollowupsRead  = "followups:read"
+	permissionFollowupsWrite = "followups:write"
+)
+
+func (app *application) createFollowupHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		VehicleID  int64     `json:"vehicle_id"`
+		AssignedTo *int64    `json:"assigned_to"`
+		Title      string    `json:"title"`
+		Notes      string    `json:"notes"`
+		DueAt      time.Time `json:"due_at"`
+		Recurrence string    `json:"recurrence"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	schedule, err := app.scheduleFor(app.contextGetUser(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	followup := &data.Followup{
+		VehicleID:  input.VehicleID,
+		AssignedTo: input.AssignedTo,
+		Title:      input.Title,
+		Notes:      input.Notes,
+		DueAt:      input.DueAt,
+		Recurrence: input.Recurrence,
+	}
+
+	// A series keeps the date asked for as its start, so that occurrences
+	// moved off closed days don't shift the ones after them.
+	if followup.Recurrence != "" {
+		start := followup.DueAt
+		followup.RecurrenceStart = &start
+	}
+
+	if !followup.DueAt.IsZero() {
+		followup.DueAt = schedule.NextBusinessMoment(followup.DueAt)
+	}
+
+	v := validator.New()
+	if data.ValidateFollowup(v, followup); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Followups.Insert(followup)
+	if err != nil {
+		app.followupErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/followups/%d", followup.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"followup": followup}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showFollowupHandler(w http.ResponseWriter, r *http.Request) {
+	followup, ok := app.readFollowupParam(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"followup": followup}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateFollowupHandler edits everything but the status. An assigned_to of 0
+// unassigns the follow-up. Changing the recurrence starts a new series at the
+// current due date. A new due date is moved to the next business moment.
+func (app *application) updateFollowupHandler(w http.ResponseWriter, r *http.Request) {
+	followup, ok := app.readFollowupParam(w, r)
+	if !ok {
+		return
+	}
+
+	if ok := app.checkVersion(r, followup.Version); !ok {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	var input struct {
+		VehicleID  *int64     `json:"vehicle_id"`
+		AssignedTo *int64     `json:"assigned_to"`
+		Title      *string    `json:"title"`
+		Notes      *string    `json:"notes"`
+		DueAt      *time.Time `json:"due_at"`
+		Recurrence *string    `json:"recurrence"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.VehicleID != nil {
+		followup.VehicleID = *input.VehicleID
+	}
+	if input.AssignedTo != nil {
+		followup.AssignedTo = input.AssignedTo
+		if *input.AssignedTo == 0 {
+			followup.AssignedTo = nil
+		}
+	}
+	if input.Title != nil {
+		followup.Title = *input.Title
+	}
+	if input.Notes != nil {
+		followup.Notes = *input.Notes
+	}
+	if input.Recurrence != nil && *input.Recurrence != followup.Recurrence {
+		followup.Recurrence = *input.Recurrence
+		followup.RecurrenceStart = nil
+		if followup.Recurrence != "" {
+			start := followup.DueAt
+			if input.DueAt != nil {
+				start = *input.DueAt
+			}
+			followup.RecurrenceStart = &start
+		}
+	}
+	if input.DueAt != nil && !input.DueAt.IsZero() {
+		schedule, err := app.scheduleFor(app.contextGetUser(r))
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		followup.DueAt = schedule.NextBusinessMoment(*input.DueAt)
+	}
+
+	v := validator.New()
+	if data.ValidateFollowup(v, followup); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Followups.Update(followup)
+	if err != nil {
+		app.followupErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"followup": followup}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteFollowupHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Followups.Delete(int64(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "followup successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listFollowupsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	var input data.FollowupFilters
+
+	input.Status = app.readString(&qs, "status", "")
+	input.AssignedTo = int64(app.readInt(&qs, "assigned_to", 0, v))
+	input.VehicleID = int64(app.readInt(&qs, "vehicle_id", 0, v))
+
+	input.Page = app.readInt(&qs, "page", 1, v)
+	input.PageSize = app.readInt(&qs, "page_size", 20, v)
+	input.Sort = app.readString(&qs, "sort", "due_at")
+
+	input.SortSafelist = []string{"id", "due_at", "created_at", "-id", "-due_at", "-created_at"}
+
+	if data.ValidateFilter(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	followups, metadata, err := app.models.Followups.GetAll(input)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"followups": followups, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createFollowupTransitionHandler moves a follow-up along its lifecycle. Like
+// any other edit, it is rejected if the follow-up changed since it was read.
+func (app *application) createFollowupTransitionHandler(w http.ResponseWriter, r *http.Request) {
+	followup, ok := app.readFollowupParam(w, r)
+	if !ok {
+		return
+	}
+
+	if ok := app.checkVersion(r, followup.Version); !ok {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	transition := &data.FollowupTransition{
+		To:     input.Status,
+		Reason: input.Reason,
+		UserID: &app.contextGetUser(r).ID,
+	}
+
+	v := validator.New()
+	if data.ValidateFollowupTransition(v, followup, transition); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	schedule, err := app.scheduleFor(app.contextGetUser(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	next, err := app.models.Followups.Transition(followup, transition, schedule)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"followup": followup, "transition": transition}
+	if next != nil {
+		env["next_followup"] = next
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listFollowupTransitionsHandler(w http.ResponseWriter, r *http.Request) {
+	followup, ok := app.readFollowupParam(w, r)
+	if !ok {
+		return
+	}
+
+	transitions, err := app.models.Followups.GetTransitions(followup.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"transitions": transitions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listFollowupOccurrencesHandler previews the next occurrences of a recurring
+// follow-up, as they would be scheduled.
+func (app *application) listFollowupOccurrencesHandler(w http.ResponseWriter, r *http.Request) {
+	followup, ok := app.readFollowupParam(w, r)
+	if !ok {
+		return
+	}
+
+	qs := r.URL.Query()
+	v := validator.New()
+
+	count := app.readInt(&qs, "count", 5, v)
+
+	v.Check(validator.Min(count, 1), "count", "must be greater than or equal to 1")
+	v.Check(validator.Max(count, 100), "count", "must be less than or equal to 100")
+	v.Check(followup.Recurrence != "", "recurrence", "the followup does not recur")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	schedule, err := app.scheduleFor(app.contextGetUser(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	occurrences, err := followup.Occurrences(count, schedule)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if occurrences == nil {
+		occurrences = []time.Time{}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"occurrences": occurrences}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// followupErrorResponse responds to an error from inserting or updating a
+// follow-up.
+func (app *application) followupErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	v := validator.New()
+
+	switch {
+	case errors.Is(err, data.ErrUnknownVehicle):
+		v.AddError("vehicle_id", "must refer to an existing vehicle")
+		app.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, data.ErrUnknownAssignee):
+		v.AddError("assigned_to", "must refer to an existing user")
+		app.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, data.ErrEditConflict):
+		app.editConflictResponse(w, r)
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readFollowupParam loads the follow-up named by the id parameter, responding
+// with a not found or server error if it can't.
+func (app *application) readFollowupParam(w http.ResponseWriter, r *http.Request) (*data.Followup, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	followup, err := app.models.Followups.Get(int64(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return followup, true
+}
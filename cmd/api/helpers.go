@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,6 +10,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
 	"github.com/julienschmidt/httprouter"
@@ -29,6 +31,36 @@ func (app *application) background(fn func()) {
 	}()
 }
 
+// periodic runs fn every interval until ctx is cancelled. Shutdown waits for
+// a run that is in progress to finish.
+func (app *application) periodic(ctx context.Context, interval time.Duration, fn func(ctx context.Context)) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				func() {
+					defer func() {
+						if err := recover(); err != nil {
+							app.logger.PrintError(fmt.Errorf("%s", err), nil)
+						}
+					}()
+
+					fn(ctx)
+				}()
+			}
+		}
+	}()
+}
+
 func (app *application) readString(q *url.Values, key string, defaultValue string) string {
 	val := q.Get(key)
 
@@ -63,6 +95,36 @@ func (app *application) readInt(q *url.Values, key string, defaultValue int, v *
 	return i
 }
 
+func (app *application) readBool(q *url.Values, key string, v *validator.Validator) *bool {
+	str := q.Get(key)
+	if str == "" {
+		return nil
+	}
+
+	b, err := strconv.ParseBool(str)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return nil
+	}
+
+	return &b
+}
+
+func (app *application) readTime(q *url.Values, key string, v *validator.Validator) *time.Time {
+	str := q.Get(key)
+	if str == "" {
+		return nil
+	}
+
+	t, err := time.Parse(time.RFC3339, str)
+	if err != nil {
+		v.AddError(key, "must be an RFC 3339 timestamp")
+		return nil
+	}
+
+	return &t
+}
+
 func (app *application) readIDParam(r *http.Request) (int, error) {
 	params := httprouter.ParamsFromContext(r.Context())
 
@@ -74,6 +136,19 @@ func (app *application) readIDParam(r *http.Request) (int, error) {
 	return id, nil
 }
 
+// readNamedIDParam reads an id from the named parameter, for routes with more
+// than one id such as /v1/vehicles/:id/documents/:document_id.
+func (app *application) readNamedIDParam(r *http.Request, name string) (int64, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.ParseInt(params.ByName(name), 10, 64)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invalid %s parameter", name)
+	}
+
+	return id, nil
+}
+
 func (app *application) checkVersion(r *http.Request, expected int) bool {
 	if r.Header.Get("X-Expected-Version") != "" {
 		if strconv.FormatInt(int64(expected), 32) != r.Header.Get("X-Expected-Version") {
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/data"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+)
+
+const invitationTTL = 7 * 24 * time.Hour
+
+func (app *application) listInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	var input struct {
+		Pending bool
+		data.Filters
+	}
+
+	if pending := app.readBool(&qs, "pending", v); pending != nil {
+		input.Pending = *pending
+	}
+
+	input.Page = app.readInt(&qs, "page", 1, v)
+	input.PageSize = app.readInt(&qs, "page_size", 20, v)
+	input.Sort = app.readString(&qs, "sort", "-created_at")
+
+	input.SortSafelist = []string{"email", "created_at", "expiry", "-email", "-created_at", "-expiry"}
+
+	if data.ValidateFilter(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	orgID := *app.contextGetUser(r).OrganizationID
+
+	invitations, metadata, err := app.models.Invitations.GetAllForOrganization(orgID, input.Pending, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"invitations": invitations, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	admin := app.contextGetUser(r)
+
+	invitation := &data.Invitation{
+		OrganizationID: *admin.OrganizationID,
+		Email:          input.Email,
+		Role:           input.Role,
+		InvitedBy:      &admin.ID,
+	}
+
+	v := validator.New()
+	if data.ValidateInvitation(v, invitation); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Invitations.New(invitation, invitationTTL)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateInvitation):
+			v.AddError("email", "a pending invitation for this email already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.sendInvitation(r, invitation)
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"invitation": invitation}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// resendInvitationHandler emails a pending invitation again with a fresh
+// token, which also restarts its expiry.
+func (app *application) resendInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	invitation, ok := app.readInvitationParam(w, r)
+	if !ok {
+		return
+	}
+
+	if invitation.AcceptedAt != nil {
+		v := validator.New()
+		v.AddError("invitation", "has already been accepted")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err := app.models.Invitations.Renew(invitation, invitationTTL)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.sendInvitation(r, invitation)
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"invitation": invitation}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Invitations.Delete(int64(id), *app.contextGetUser(r).OrganizationID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "invitation successfully revoked"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// acceptInvitationHandler joins the invitee to the organization. An existing
+// account is linked as is; otherwise a new one is created from the name and
+// password given. The token proves ownership of the email, so either way the
+// account ends up activated.
+func (app *application) acceptInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TokenPlaintext string `json:"token"`
+		Name           string `json:"name"`
+		Password       string `json:"password"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidatePlaintextToken(v, input.TokenPlaintext); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	invitation, err := app.models.Invitations.GetForToken(input.TokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			v.AddError("token", "invalid or expired token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.models.Users.GetByEmail(invitation.Email)
+	switch {
+	case err == nil:
+		if user.IsDeactivated() {
+			app.deactivatedAccountResponse(w, r)
+			return
+		}
+
+		if user.OrganizationID != nil && *user.OrganizationID != invitation.OrganizationID {
+			v.AddError("email", "this account already belongs to another organization")
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+	case errors.Is(err, data.ErrNoRecordFound):
+		user = &data.User{
+			Name:  input.Name,
+			Email: invitation.Email,
+		}
+
+		err = user.Password.Set(input.Password, app.config.password)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if data.ValidateUser(v, user, app.passwordPolicy); !v.Valid() {
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+	default:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Invitations.Accept(invitation, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			v.AddError("token", "invalid or expired token")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateEmail):
+			v.AddError("email", "a user with this email already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) sendInvitation(r *http.Request, invitation *data.Invitation) {
+	admin := app.contextGetUser(r)
+
+	app.background(func() {
+		data := map[string]any{
+			"invitedBy":       admin.Name,
+			"role":            invitation.Role,
+			"invitationToken": invitation.Token.Plaintext,
+		}
+
+		err := app.mailer.Send(invitation.Email, "invitation.tmpl", data)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{
+				"email":         invitation.Email,
+				"invitation_id": strconv.FormatInt(invitation.ID, 10),
+			})
+		}
+	})
+}
+
+// readInvitationParam loads the invitation named by the id parameter from the
+// caller's organization, responding with a not found or server error if it
+// can't.
+func (app *application) readInvitationParam(w http.ResponseWriter, r *http.Request) (*data.Invitation, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	invitation, err := app.models.Invitations.Get(int64(id), *app.contextGetUser(r).OrganizationID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return invitation, true
+}
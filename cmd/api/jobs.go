@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/imaging"
+)
+
+// publishedVars are the expvar variables served by debugVarsHandler.
+var publishedVars = []string{"expired_tokens_deleted", "orphaned_blobs_deleted"}
+
+// orphanedBlobGrace is how long stored content stays unreferenced before it
+// is removed. It outlasts thumbnail generation, which may still be writing
+// thumbnails of content whose last attachment was just deleted.
+const orphanedBlobGrace = time.Hour
+
+// orphanedBlobBatchSize is how many orphaned blobs one sweep removes.
+const orphanedBlobBatchSize = 100
+
+// startJobs starts the background jobs. They stop when ctx is cancelled.
+func (app *application) startJobs(ctx context.Context) {
+	if app.config.tokenCleanup.interval > 0 {
+		tokensDeleted := expvar.NewInt("expired_tokens_deleted")
+
+		app.periodic(ctx, app.config.tokenCleanup.interval, func(ctx context.Context) {
+			app.deleteExpiredTokens(ctx, tokensDeleted)
+		})
+	}
+
+	if app.config.attachments.gcInterval > 0 {
+		blobsDeleted := expvar.NewInt("orphaned_blobs_deleted")
+
+		app.periodic(ctx, app.config.attachments.gcInterval, func(ctx context.Context) {
+			app.deleteOrphanedBlobs(ctx, blobsDeleted)
+		})
+	}
+}
+
+// deleteExpiredTokens deletes expired tokens in batches, pausing between
+// batches so the cleanup never holds locks on the tokens table for long.
+func (app *application) deleteExpiredTokens(ctx context.Context, counter *expvar.Int) {
+	var total int64
+
+	for ctx.Err() == nil {
+		n, err := app.models.Tokens.DeleteExpired(ctx, app.config.tokenCleanup.batchSize)
+		if err != nil {
+			app.logger.PrintError(err, nil)
+			break
+		}
+
+		total += n
+		counter.Add(n)
+
+		if n < int64(app.config.tokenCleanup.batchSize) {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	if total > 0 {
+		app.logger.PrintInfo("deleted expired tokens", map[string]string{
+			"count": strconv.FormatInt(total, 10),
+		})
+	}
+}
+
+// deleteOrphanedBlobs removes stored attachment content, and its thumbnails,
+// that no attachment has referred to for orphanedBlobGrace. Content is marked
+// when it loses its last attachment, including when its vehicle is deleted,
+// and removed by a later run.
+func (app *application) deleteOrphanedBlobs(ctx context.Context, counter *expvar.Int) {
+	_, err := app.models.Attachments.MarkOrphanedBlobs(ctx)
+	if err != nil {
+		app.logger.PrintError(err, nil)
+		return
+	}
+
+	remove := func(ctx context.Context, sha256 string) error {
+		keys := []string{sha256}
+		for _, size := range imaging.Sizes {
+			keys = append(keys, thumbnailKey(sha256, size.Name))
+		}
+
+		for _, key := range keys {
+			err := app.storage.Delete(ctx, key)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	var total int64
+
+	for ctx.Err() == nil {
+		n, err := app.models.Attachments.SweepOrphanedBlobs(ctx, time.Now().Add(-orphanedBlobGrace), orphanedBlobBatchSize, remove)
+		total += n
+		counter.Add(n)
+
+		if err != nil {
+			app.logger.PrintError(err, nil)
+			break
+		}
+
+		if n < orphanedBlobBatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		app.logger.PrintInfo("deleted orphaned attachment files", map[string]string{
+			"count": strconv.FormatInt(total, 10),
+		})
+	}
+}
+
+// debugVarsHandler serves the job counters. Unlike expvar.Handler it leaves
+// out the default variables, as cmdline carries the secrets passed as flags.
+func (app *application) debugVarsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := envelope{}
+
+	for _, name := range publishedVars {
+		if v := expvar.Get(name); v != nil {
+			vars[name] = json.RawMessage(v.String())
+		}
+	}
+
+	err := app.writeJSON(w, http.StatusOK, vars, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
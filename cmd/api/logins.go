@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/data"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+	"github.com/tomasen/realip"
+)
+
+func (app *application) newLogin(r *http.Request, email, method string, success bool) *data.Login {
+	ip := realip.FromRequest(r)
+	userAgent := r.UserAgent()
+
+	return &data.Login{
+		Email:       email,
+		Method:      method,
+		IP:          ip,
+		UserAgent:   userAgent,
+		Fingerprint: data.DeviceFingerprint(ip, userAgent),
+		Success:     success,
+	}
+}
+
+// recordLogin adds a successful login to the user's history. The first login
+// from an unknown device, other than the user's very first login, triggers an
+// alert email with a token that revokes the new session. Failures are logged
+// rather than failing the login.
+func (app *application) recordLogin(r *http.Request, user *data.User, method string, family []byte) {
+	login := app.newLogin(r, user.Email, method, true)
+	login.UserID = &user.ID
+
+	seen, hasHistory, err := app.models.Logins.DeviceHistory(user.ID, login.Fingerprint)
+	if err == nil {
+		err = app.models.Logins.Insert(login)
+	}
+
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{
+			"user_id": strconv.FormatInt(user.ID, 10),
+		})
+		return
+	}
+
+	if seen || !hasHistory {
+		return
+	}
+
+	revokeToken, err := app.models.Tokens.NewInFamily(user.ID, time.Hour*24*30, data.ScopeSessionRevoke, family)
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{
+			"user_id": strconv.FormatInt(user.ID, 10),
+		})
+		return
+	}
+
+	app.background(func() {
+		data := map[string]any{
+			"time":        login.CreatedAt.UTC().Format(time.RFC1123),
+			"ip":          login.IP,
+			"userAgent":   login.UserAgent,
+			"revokeToken": revokeToken.Plaintext,
+		}
+
+		err := app.mailer.Send(user.Email, "new_device_login.tmpl", data)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{
+				"email": user.Email,
+			})
+		}
+	})
+}
+
+// recordFailedLogin adds a failed login to the history. The user is nil when
+// nobody is registered with the email.
+func (app *application) recordFailedLogin(r *http.Request, email, method string, user *data.User) {
+	login := app.newLogin(r, email, method, false)
+	if user != nil {
+		login.UserID = &user.ID
+	}
+
+	err := app.models.Logins.Insert(login)
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{
+			"email": email,
+		})
+	}
+}
+
+func (app *application) listLoginsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	var input struct {
+		data.Filters
+	}
+
+	input.Page = app.readInt(&qs, "page", 1, v)
+	input.PageSize = app.readInt(&qs, "page_size", 20, v)
+	input.Sort = app.readString(&qs, "sort", "-created_at")
+
+	input.SortSafelist = []string{"created_at", "-created_at"}
+
+	if data.ValidateFilter(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	logins, metadata, err := app.models.Logins.GetAllForUser(app.contextGetUser(r).ID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"logins": logins, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// revokeSessionHandler signs out the session a new device alert was sent for,
+// using the token from the alert email.
+func (app *application) revokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TokenPlaintext string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidatePlaintextToken(v, input.TokenPlaintext); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	token, err := app.models.Tokens.Get(input.TokenPlaintext, data.ScopeSessionRevoke)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.invalidTokenResponse(w, r, "session revocation")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Tokens.DeleteFamily(token.Family)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "the session has been signed out"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/data"
+)
+
+// loginFailureWindow is how long a failed login counts towards a lockout.
+const loginFailureWindow = 24 * time.Hour
+
+// loginDelay is the wait required after the last failure before another
+// attempt is checked. It doubles with every failure past the threshold.
+func (app *application) loginDelay(failures int) time.Duration {
+	over := failures - app.config.login.delayThreshold
+	if over < 0 {
+		return 0
+	}
+
+	if over > 6 {
+		over = 6
+	}
+
+	return time.Second << over
+}
+
+// loginAllowed reports whether the account and the client IP may attempt a
+// login right now.
+func (app *application) loginAllowed(email, ip string) (bool, error) {
+	now := time.Now()
+
+	for kind, key := range map[string]string{data.ThrottleAccount: email, data.ThrottleIP: ip} {
+		t, err := app.models.LoginThrottles.Get(kind, key)
+		if err != nil {
+			if errors.Is(err, data.ErrNoRecordFound) {
+				continue
+			}
+			return false, err
+		}
+
+		if t.Locked(now) || now.Before(t.LastFailedAt.Add(app.loginDelay(t.Failures))) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// recordLoginFailure counts a failed login against the account and the IP
+// and locks whichever crossed its threshold. The account owner, if there is
+// one, is told by email.
+func (app *application) recordLoginFailure(email, ip string, user *data.User) error {
+	account, err := app.models.LoginThrottles.RecordFailure(data.ThrottleAccount, email, loginFailureWindow)
+	if err != nil {
+		return err
+	}
+
+	if account.Failures >= app.config.login.lockoutThreshold {
+		lockedUntil := time.Now().Add(app.config.login.lockoutDuration)
+
+		err = app.models.LoginThrottles.Lock(data.ThrottleAccount, email, lockedUntil)
+		if err != nil {
+			return err
+		}
+
+		app.logger.PrintInfo("account locked after failed logins", map[string]string{
+			"email": email,
+			"ip":    ip,
+		})
+
+		if user != nil {
+			app.background(func() {
+				data := map[string]any{
+					"lockedUntil": lockedUntil.UTC().Format(time.RFC1123),
+				}
+
+				err := app.mailer.Send(user.Email, "account_locked.tmpl", data)
+				if err != nil {
+					app.logger.PrintError(err, map[string]string{
+						"email": user.Email,
+					})
+				}
+			})
+		}
+	}
+
+	client, err := app.models.LoginThrottles.RecordFailure(data.ThrottleIP, ip, loginFailureWindow)
+	if err != nil {
+		return err
+	}
+
+	if client.Failures >= app.config.login.ipLockoutThreshold {
+		err = app.models.LoginThrottles.Lock(data.ThrottleIP, ip, time.Now().Add(app.config.login.lockoutDuration))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (app *application) failedLoginResponse(w http.ResponseWriter, r *http.Request, email, ip string, user *data.User) {
+	app.recordFailedLogin(r, email, data.LoginMethodPassword, user)
+
+	err := app.recordLoginFailure(email, ip, user)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.invalidCredentialsResponse(w, r)
+}
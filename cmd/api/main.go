@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
 	"flag"
 	"fmt"
@@ -12,7 +13,11 @@ import (
 
 	"github.com/PriyanshuSharma23/follow-ups-server/internals/data"
 	"github.com/PriyanshuSharma23/follow-ups-server/internals/jsonlogger"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/jwt"
 	"github.com/PriyanshuSharma23/follow-ups-server/internals/mailer"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/oidc"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/passwordpolicy"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/storage"
 	_ "github.com/lib/pq"
 )
 
@@ -44,14 +49,74 @@ type config struct {
 		burst   int
 		enabled bool
 	}
+	auth struct {
+		mode           string
+		accessTokenTTL time.Duration
+		jwtKeys        []jwt.Key
+	}
+	password       data.PasswordParams
+	passwordPolicy struct {
+		minScore   int
+		breachFile string
+	}
+	tokenCleanup struct {
+		interval  time.Duration
+		batchSize int
+	}
+	login struct {
+		delayThreshold     int
+		lockoutThreshold   int
+		ipLockoutThreshold int
+		lockoutDuration    time.Duration
+	}
+	documents struct {
+		followupLead time.Duration
+	}
+	storage struct {
+		backend       string
+		dir           string
+		publicURL     string
+		signingSecret string
+		s3            storage.S3Config
+	}
+	attachments struct {
+		maxSize          int64
+		urlTTL           time.Duration
+		gcInterval       time.Duration
+		thumbnailWorkers int
+	}
+	comments struct {
+		editWindow time.Duration
+	}
+	oidc struct {
+		issuer       string
+		clientID     string
+		clientSecret string
+		redirectURL  string
+	}
 }
 
+const (
+	authModeDatabase  = "database"
+	authModeStateless = "stateless"
+)
+
+const (
+	storageBackendLocal = "local"
+	storageBackendS3    = "s3"
+)
+
 type application struct {
-	config config
-	logger *jsonlogger.Logger
-	models data.Models
-	mailer mailer.Mailer
-	wg     sync.WaitGroup
+	config         config
+	logger         *jsonlogger.Logger
+	models         data.Models
+	mailer         mailer.Mailer
+	keyring        *jwt.Keyring
+	passwordPolicy *passwordpolicy.Policy
+	oidc           *oidc.Provider
+	storage        storage.Storage
+	thumbnailSlots chan struct{}
+	wg             sync.WaitGroup
 }
 
 func main() {
@@ -76,6 +141,64 @@ func main() {
 	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "FollowUps <inbox.priyanshu@gmail.com>", "SMTP sender")
 	flag.IntVar(&cfg.smtp.retries, "smtp-retires", 3, "SMTP number of retries for failed email delivery")
 
+	flag.StringVar(&cfg.auth.mode, "auth-mode", authModeDatabase, "access token mode: (database | stateless)")
+	flag.DurationVar(&cfg.auth.accessTokenTTL, "auth-access-token-ttl", 15*time.Minute, "Lifetime of stateless access tokens")
+	flag.Func("auth-jwt-keys", "Access token signing keys as kid:secret pairs (space separated, the first one signs)", func(s string) error {
+		for _, pair := range strings.Fields(s) {
+			kid, secret, ok := strings.Cut(pair, ":")
+			if !ok {
+				return fmt.Errorf("invalid key %q, expected kid:secret", pair)
+			}
+
+			cfg.auth.jwtKeys = append(cfg.auth.jwtKeys, jwt.Key{ID: kid, Secret: []byte(secret)})
+		}
+		return nil
+	})
+
+	var argon2Memory, argon2Iterations, argon2Parallelism uint
+
+	flag.StringVar(&cfg.password.Algorithm, "password-algorithm", data.DefaultPasswordParams.Algorithm, "Password hashing algorithm for new hashes: (argon2id | bcrypt)")
+	flag.IntVar(&cfg.password.BcryptCost, "password-bcrypt-cost", data.DefaultPasswordParams.BcryptCost, "bcrypt cost")
+	flag.UintVar(&argon2Memory, "password-argon2-memory", uint(data.DefaultPasswordParams.Argon2Memory), "argon2id memory in KiB")
+	flag.UintVar(&argon2Iterations, "password-argon2-iterations", uint(data.DefaultPasswordParams.Argon2Iterations), "argon2id number of iterations")
+	flag.UintVar(&argon2Parallelism, "password-argon2-parallelism", uint(data.DefaultPasswordParams.Argon2Parallelism), "argon2id number of threads")
+
+	flag.IntVar(&cfg.passwordPolicy.minScore, "password-min-score", 2, "Minimum password strength score from 0 to 4")
+	flag.StringVar(&cfg.passwordPolicy.breachFile, "password-breach-file", "", "Sorted hash file of breached passwords (defaults to the embedded common password list)")
+
+	flag.DurationVar(&cfg.tokenCleanup.interval, "token-cleanup-interval", time.Hour, "How often expired tokens are deleted (0 disables the cleanup)")
+	flag.IntVar(&cfg.tokenCleanup.batchSize, "token-cleanup-batch-size", 1000, "Expired tokens deleted per statement")
+
+	flag.IntVar(&cfg.login.delayThreshold, "login-delay-threshold", 3, "Failed logins before each further attempt is delayed")
+	flag.IntVar(&cfg.login.lockoutThreshold, "login-lockout-threshold", 10, "Failed logins before an account is locked")
+	flag.IntVar(&cfg.login.ipLockoutThreshold, "login-ip-lockout-threshold", 50, "Failed logins before a client IP is locked")
+	flag.DurationVar(&cfg.login.lockoutDuration, "login-lockout-duration", 15*time.Minute, "How long a locked account or IP stays locked")
+
+	flag.DurationVar(&cfg.documents.followupLead, "document-followup-lead", 30*24*time.Hour, "How long before a vehicle document expires its renewal follow-up is due")
+
+	flag.StringVar(&cfg.storage.backend, "storage-backend", storageBackendLocal, "Attachment storage: (local | s3)")
+	flag.StringVar(&cfg.storage.dir, "storage-dir", "./uploads", "Directory of the local attachment storage")
+	flag.StringVar(&cfg.storage.publicURL, "storage-public-url", "", "Base URL of this API, used in download links of locally stored attachments")
+	flag.StringVar(&cfg.storage.signingSecret, "storage-signing-secret", "", "Secret that signs download links of locally stored attachments (random when empty)")
+	flag.StringVar(&cfg.storage.s3.Endpoint, "storage-s3-endpoint", "", "S3 compatible endpoint URL, such as http://localhost:9000 for MinIO")
+	flag.StringVar(&cfg.storage.s3.Region, "storage-s3-region", "us-east-1", "S3 region")
+	flag.StringVar(&cfg.storage.s3.Bucket, "storage-s3-bucket", "", "S3 bucket")
+	flag.StringVar(&cfg.storage.s3.AccessKey, "storage-s3-access-key", "", "S3 access key")
+	flag.StringVar(&cfg.storage.s3.SecretKey, "storage-s3-secret-key", "", "S3 secret key")
+	flag.BoolVar(&cfg.storage.s3.PathStyle, "storage-s3-path-style", true, "Address the bucket in the URL path rather than the host name")
+
+	flag.Int64Var(&cfg.attachments.maxSize, "attachments-max-size", 10<<20, "Largest attachment upload in bytes")
+	flag.DurationVar(&cfg.attachments.urlTTL, "attachments-url-ttl", 15*time.Minute, "How long attachment download links stay valid")
+	flag.IntVar(&cfg.attachments.thumbnailWorkers, "attachments-thumbnail-workers", 2, "How many photos thumbnails are made of at the same time")
+	flag.DurationVar(&cfg.attachments.gcInterval, "attachments-gc-interval", time.Hour, "How often stored files no attachment refers to are removed (0 disables the cleanup)")
+
+	flag.DurationVar(&cfg.comments.editWindow, "comment-edit-window", 15*time.Minute, "How long after posting a comment its author may edit it")
+
+	flag.StringVar(&cfg.oidc.issuer, "oidc-issuer", "", "OpenID Connect issuer URL, leave empty to disable SSO")
+	flag.StringVar(&cfg.oidc.clientID, "oidc-client-id", "", "OpenID Connect client ID")
+	flag.StringVar(&cfg.oidc.clientSecret, "oidc-client-secret", "", "OpenID Connect client secret")
+	flag.StringVar(&cfg.oidc.redirectURL, "oidc-redirect-url", "", "OpenID Connect redirect URL registered with the provider")
+
 	flag.Func("cors-trusted-origins", "Trusted CORS origins (space separated)", func(s string) error {
 		cfg.cors.trustedOrigins = strings.Fields(s)
 		return nil
@@ -85,6 +208,10 @@ func main() {
 
 	flag.Parse()
 
+	cfg.password.Argon2Memory = uint32(argon2Memory)
+	cfg.password.Argon2Iterations = uint32(argon2Iterations)
+	cfg.password.Argon2Parallelism = uint8(argon2Parallelism)
+
 	if *displayVersion {
 		fmt.Printf("Version:   \t%s\n", version)
 		fmt.Printf("Build Time:\t%s\n", buildTime)
@@ -93,6 +220,31 @@ func main() {
 
 	logger := jsonlogger.NewLogger(os.Stdout, jsonlogger.LevelInfo)
 
+	err := cfg.password.Validate()
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	if cfg.tokenCleanup.batchSize < 1 {
+		logger.PrintFatal(fmt.Errorf("invalid token cleanup batch size %d, must be at least 1", cfg.tokenCleanup.batchSize), nil)
+	}
+
+	if cfg.attachments.thumbnailWorkers < 1 {
+		logger.PrintFatal(fmt.Errorf("invalid thumbnail worker count %d, must be at least 1", cfg.attachments.thumbnailWorkers), nil)
+	}
+
+	if cfg.documents.followupLead < 0 {
+		logger.PrintFatal(fmt.Errorf("invalid document follow-up lead %s, must not be negative", cfg.documents.followupLead), nil)
+	}
+
+	var breachList *passwordpolicy.HashList
+	if cfg.passwordPolicy.breachFile != "" {
+		breachList, err = passwordpolicy.ReadHashFile(cfg.passwordPolicy.breachFile)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+	}
+
 	db, err := openDB(cfg)
 	if err != nil {
 		logger.PrintFatal(err, nil)
@@ -115,10 +267,37 @@ func main() {
 	}
 
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
-		mailer: m,
+		config:         cfg,
+		logger:         logger,
+		models:         data.NewModels(db),
+		mailer:         m,
+		passwordPolicy: passwordpolicy.New(cfg.passwordPolicy.minScore, breachList),
+		thumbnailSlots: make(chan struct{}, cfg.attachments.thumbnailWorkers),
+	}
+
+	switch cfg.auth.mode {
+	case authModeDatabase:
+	case authModeStateless:
+		app.keyring, err = jwt.NewKeyring(cfg.auth.jwtKeys...)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+	default:
+		logger.PrintFatal(fmt.Errorf("invalid auth mode %q", cfg.auth.mode), nil)
+	}
+
+	app.storage, err = openStorage(cfg, logger)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	if cfg.oidc.issuer != "" {
+		app.oidc = oidc.NewProvider(oidc.Config{
+			Issuer:       cfg.oidc.issuer,
+			ClientID:     cfg.oidc.clientID,
+			ClientSecret: cfg.oidc.clientSecret,
+			RedirectURL:  cfg.oidc.redirectURL,
+		}, nil)
 	}
 
 	err = app.serve()
@@ -153,3 +332,27 @@ func openDB(cfg config) (*sql.DB, error) {
 
 	return db, nil
 }
+
+func openStorage(cfg config, logger *jsonlogger.Logger) (storage.Storage, error) {
+	switch cfg.storage.backend {
+	case storageBackendLocal:
+		secret := []byte(cfg.storage.signingSecret)
+
+		if len(secret) == 0 {
+			secret = make([]byte, 32)
+
+			_, err := rand.Read(secret)
+			if err != nil {
+				return nil, err
+			}
+
+			logger.PrintInfo("no storage signing secret set, attachment download links will not survive a restart", nil)
+		}
+
+		return storage.NewLocal(cfg.storage.dir, strings.TrimSuffix(cfg.storage.publicURL, "/")+"/v1/files/", secret)
+	case storageBackendS3:
+		return storage.NewS3(cfg.storage.s3)
+	default:
+		return nil, fmt.Errorf("invalid storage backend %q", cfg.storage.backend)
+	}
+}
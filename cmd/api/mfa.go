@@ -0,0 +1,268 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/data"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/totp"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+)
+
+const totpIssuer = "FollowUps"
+
+func (app *application) enrollTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := app.models.Users.Get(app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	_, err = app.models.MFA.EnrollTOTP(user.ID, secret)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			v := validator.New()
+			v.AddError("totp", "two-factor authentication is already enabled")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{
+		"totp": map[string]string{
+			"secret":      secret,
+			"otpauth_uri": totp.URI(totpIssuer, user.Email, secret),
+		},
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) confirmTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code string `json:"code"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateOTP(v, input.Code); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	t, err := app.models.MFA.GetTOTP(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			v.AddError("totp", "enrollment must be started first")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if t.Enabled {
+		v.AddError("totp", "two-factor authentication is already enabled")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	step, ok := totp.Validate(t.Secret, input.Code, time.Now(), 1)
+	if !ok {
+		v.AddError("code", "invalid code")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	t.Enabled = true
+	t.LastUsedStep = step
+
+	err = app.models.MFA.UpdateTOTP(t)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	codes, err := app.models.MFA.NewRecoveryCodes(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"recovery_codes": codes}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) disableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	t, err := app.models.MFA.GetTOTP(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if t.Enabled {
+		ok, err := app.verifySecondFactor(t, input.Code, input.RecoveryCode)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !ok {
+			v := validator.New()
+			v.AddError("code", "invalid code")
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+	}
+
+	err = app.models.MFA.DeleteTOTP(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "two-factor authentication disabled"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createMFAAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		MFAToken     string `json:"mfa_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidatePlaintextToken(v, input.MFAToken); !v.Valid() {
+		app.invalidTokenResponse(w, r, "mfa")
+		return
+	}
+
+	v.Check(input.Code != "" || input.RecoveryCode != "", "code", "must be provided")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := app.models.Users.GetForToken(input.MFAToken, data.ScopeMFA)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.invalidTokenResponse(w, r, "mfa")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	t, err := app.models.MFA.GetTOTP(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	ok, err := app.verifySecondFactor(t, input.Code, input.RecoveryCode)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// The mfa token is single use whatever the outcome, so each guess at the
+	// code costs a full password login.
+	err = app.models.Tokens.DeleteAllForUser(user.ID, data.ScopeMFA)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !ok {
+		app.recordFailedLogin(r, user.Email, data.LoginMethodTOTP, user)
+		app.invalidCredentialsResponse(w, r)
+		return
+	}
+
+	app.startSession(w, r, user, data.LoginMethodTOTP)
+}
+
+// verifySecondFactor checks a TOTP code, refusing codes from a step that was
+// already used, or else consumes a recovery code.
+func (app *application) verifySecondFactor(t *data.TOTP, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		step, ok := totp.Validate(t.Secret, code, time.Now(), 1)
+		if !ok || step <= t.LastUsedStep {
+			return false, nil
+		}
+
+		t.LastUsedStep = step
+
+		err := app.models.MFA.UpdateTOTP(t)
+		if err != nil {
+			if errors.Is(err, data.ErrEditConflict) {
+				return false, nil
+			}
+			return false, err
+		}
+
+		return true, nil
+	}
+
+	if recoveryCode != "" {
+		return app.models.MFA.UseRecoveryCode(t.UserID, recoveryCode)
+	}
+
+	return false, nil
+}
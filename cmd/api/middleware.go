@@ -84,6 +84,12 @@ func (app *application) rateLimiter(next http.Handler) http.Handler {
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Authorization")
+		w.Header().Add("Vary", "X-API-Key")
+
+		if key := r.Header.Get("X-API-Key"); key != "" {
+			app.authenticateAPIKey(w, r, next, key)
+			return
+		}
 
 		header := r.Header.Get("Authorization")
 		if header == "" {
@@ -93,6 +99,11 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		}
 
 		headerParts := strings.Split(header, " ")
+		if len(headerParts) == 2 && headerParts[0] == "ApiKey" {
+			app.authenticateAPIKey(w, r, next, headerParts[1])
+			return
+		}
+
 		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
 			app.invalidAuthenticationTokenResponse(w, r)
 			return
@@ -100,6 +111,19 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 
 		token := headerParts[1]
 
+		if app.keyring != nil && strings.Count(token, ".") == 2 {
+			user, permissions, err := app.verifyAccessToken(token)
+			if err != nil {
+				app.invalidAuthenticationTokenResponse(w, r)
+				return
+			}
+
+			r = app.contextSetUser(r, user)
+			r = app.contextSetPermissions(r, permissions)
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		v := validator.New()
 
 		if data.ValidatePlaintextToken(v, token); !v.Valid() {
@@ -111,18 +135,176 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		if err != nil {
 			switch {
 			case errors.Is(err, data.ErrNoRecordFound):
-				app.invalidAuthenticationTokenResponse(w, r)
+				app.authenticateImpersonation(w, r, next, token)
 			default:
 				app.serverErrorResponse(w, r, err)
 			}
 			return
 		}
 
+		if user.IsDeactivated() {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
 		r = app.contextSetUser(r, user)
 		next.ServeHTTP(w, r)
 	})
 }
 
+// authenticateAPIKey authenticates the request as the owner of the key. The
+// request only gets the key's scopes that the owner still holds.
+func (app *application) authenticateAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, plaintextKey string) {
+	v := validator.New()
+
+	if data.ValidatePlaintextAPIKey(v, plaintextKey); !v.Valid() {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
+	key, err := app.models.APIKeys.GetForPlaintext(plaintextKey)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.models.Users.Get(key.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if user.IsDeactivated() {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
+	userPermissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	permissions := data.Permissions{}
+	for _, scope := range key.Scopes {
+		if userPermissions.Include(scope) {
+			permissions = append(permissions, scope)
+		}
+	}
+
+	app.background(func() {
+		err := app.models.APIKeys.Touch(key.ID)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{
+				"api_key": key.Prefix,
+			})
+		}
+	})
+
+	r = app.contextSetUser(r, user)
+	r = app.contextSetPermissions(r, permissions)
+	next.ServeHTTP(w, r)
+}
+
+// authenticateImpersonation authenticates the request as the impersonated
+// user. Writes are refused unless the session is elevated, and every request,
+// refused or not, is written to the audit log. Elevated writes are logged
+// before they run.
+func (app *application) authenticateImpersonation(w http.ResponseWriter, r *http.Request, next http.Handler, token string) {
+	session, err := app.models.Impersonations.GetForToken(token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.models.Users.Get(session.UserID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	admin, err := app.models.Users.Get(session.AdminID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	adminPermissions, err := app.models.Permissions.GetAllForUser(admin.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// The session ends as soon as the admin loses the right to start it.
+	if !admin.Activated || !adminPermissions.Include(permissionUsersAdmin) {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
+	r = app.contextSetUser(r, user)
+	r = app.contextSetImpersonation(r, &impersonation{session: session, admin: admin})
+
+	entry := &data.ImpersonationAuditEntry{
+		SessionID: session.ID,
+		AdminID:   admin.ID,
+		UserID:    user.ID,
+		Method:    r.Method,
+		Path:      r.URL.RequestURI(),
+		IP:        realip.FromRequest(r),
+	}
+
+	handler := next
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		if !session.Elevated {
+			handler = http.HandlerFunc(app.readOnlyImpersonationResponse)
+			break
+		}
+
+		// An elevated write only runs once it is in the audit log, so that a
+		// failed audit write can't leave a change without a trace. Its status
+		// is filled in when the handler returns.
+		err = app.models.Impersonations.InsertAuditEntry(entry)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	m := httpsnoop.CaptureMetrics(handler, w, r)
+	entry.Status = m.Code
+
+	app.background(func() {
+		var err error
+		if entry.ID == 0 {
+			err = app.models.Impersonations.InsertAuditEntry(entry)
+		} else {
+			err = app.models.Impersonations.UpdateAuditEntryStatus(entry)
+		}
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{
+				"impersonation_session": strconv.FormatInt(session.ID, 10),
+			})
+		}
+	})
+}
+
 func (app *application) requireAuthentication(next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := app.contextGetUser(r)
@@ -150,26 +332,55 @@ func (app *application) requireActivatedUser(next http.HandlerFunc) http.Handler
 	return app.requireAuthentication(fn)
 }
 
-// func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
-// 	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-// 		user := app.contextGetUser(r)
+// requireOrganization rejects users who don't belong to an organization, so
+// that handlers behind it can rely on OrganizationID being set.
+func (app *application) requireOrganization(next http.HandlerFunc) http.HandlerFunc {
+	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
 
-// 		permissions, err := app.models.Permissions.GetAllForUser(user.ID)
-// 		if err != nil {
-// 			app.serverErrorResponse(w, r, err)
-// 			return
-// 		}
+		if user.OrganizationID == nil {
+			app.noOrganizationResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+
+	return app.requireActivatedUser(fn)
+}
+
+// denyImpersonation refuses the request during impersonation, even in an
+// elevated session. It guards the routes that create or revoke credentials or
+// start another impersonation, whose effects would outlive the session.
+func (app *application) denyImpersonation(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.contextGetImpersonation(r) != nil {
+			app.impersonationNotPermittedResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		permissions, err := app.userPermissions(r)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
 
-// 		if !permissions.Include(code) {
-// 			app.notPermittedResponse(w, r)
-// 			return
-// 		}
+		if !permissions.Include(code) {
+			app.notPermittedResponse(w, r)
+			return
+		}
 
-// 		next.ServeHTTP(w, r)
-// 	})
+		next.ServeHTTP(w, r)
+	})
 
-// 	return app.requireActivatedUser(fn)
-// }
+	return app.requireActivatedUser(fn)
+}
 
 func (app *application) enableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -186,7 +397,7 @@ func (app *application) enableCORS(next http.Handler) http.Handler {
 
 					if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
 						w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, PUT, PATCH, DELETE")
-						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, X-API-Key")
 
 						w.WriteHeader(http.StatusOK)
 						return
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/data"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/oidc"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+)
+
+func (app *application) createOIDCAuthorizationHandler(w http.ResponseWriter, r *http.Request) {
+	if app.oidc == nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	state, err := oidc.NewState()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	nonce, err := oidc.NewNonce()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	codeVerifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	authorizationURL, err := app.oidc.AuthCodeURL(r.Context(), state, nonce, codeVerifier)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.OIDC.InsertState(&data.OIDCLoginState{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		Expiry:       time.Now().Add(10 * time.Minute),
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"authorization_url": authorizationURL, "state": state}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createOIDCAuthenticationTokenHandler completes the authorization code flow.
+// Second factors are left to the identity provider, so a valid ID token starts
+// a session directly.
+func (app *application) createOIDCAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if app.oidc == nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Code  string `json:"code"`
+		State string `json:"state"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(validator.NotBlank(input.Code), "code", "must be provided")
+	v.Check(validator.NotBlank(input.State), "state", "must be provided")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	loginState, err := app.models.OIDC.ConsumeState(input.State)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.invalidTokenResponse(w, r, "state")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	rawIDToken, err := app.oidc.Exchange(r.Context(), input.Code, loginState.CodeVerifier)
+	if err != nil {
+		switch {
+		case errors.Is(err, oidc.ErrExchangeFailed):
+			app.invalidCredentialsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	claims, err := app.oidc.VerifyIDToken(r.Context(), rawIDToken, loginState.Nonce)
+	if err != nil {
+		switch {
+		case errors.Is(err, oidc.ErrInvalidIDToken):
+			app.invalidCredentialsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.userForOIDCClaims(claims)
+	if err != nil {
+		switch {
+		case errors.Is(err, errUnverifiedEmail):
+			app.invalidCredentialsResponse(w, r)
+		case errors.Is(err, errInactiveAccount):
+			app.inactiveAccountResponse(w, r)
+		case errors.Is(err, errDeactivatedAccount):
+			app.deactivatedAccountResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.startSession(w, r, user, data.LoginMethodOIDC)
+}
+
+var (
+	errUnverifiedEmail    = errors.New("identity provider did not verify the email address")
+	errInactiveAccount    = errors.New("account is not activated")
+	errDeactivatedAccount = errors.New("account has been deactivated")
+)
+
+// userForOIDCClaims finds the user linked to the identity. New identities are
+// linked by verified email to an existing user, which the verified email
+// activates, or to a newly created one. Users of linked identities must still
+// be activated.
+func (app *application) userForOIDCClaims(claims *oidc.Claims) (*data.User, error) {
+	userID, err := app.models.OIDC.GetUserIDForIdentity(app.oidc.Issuer(), claims.Subject)
+	if err == nil {
+		user, err := app.models.Users.Get(userID)
+		if err != nil {
+			return nil, err
+		}
+
+		if user.IsDeactivated() {
+			return nil, errDeactivatedAccount
+		}
+
+		// The account was active when the identity was linked, so it has
+		// been switched off since and the provider can't turn it back on.
+		if !user.Activated {
+			return nil, errInactiveAccount
+		}
+
+		return user, nil
+	}
+
+	if !errors.Is(err, data.ErrNoRecordFound) {
+		return nil, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, errUnverifiedEmail
+	}
+
+	user, err := app.models.Users.GetByEmail(claims.Email)
+	switch {
+	case errors.Is(err, data.ErrNoRecordFound):
+		user, err = app.createOIDCUser(claims)
+		if err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	case user.IsDeactivated():
+		return nil, errDeactivatedAccount
+	case !user.Activated:
+		user.Activated = true
+
+		err = app.models.Users.UpdateUser(user)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = app.models.OIDC.LinkIdentity(app.oidc.Issuer(), claims.Subject, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (app *application) createOIDCUser(claims *oidc.Claims) (*data.User, error) {
+	name := claims.Name
+	if name == "" {
+		name = claims.Email
+	}
+
+	user := &data.User{
+		Name:      name,
+		Email:     claims.Email,
+		Activated: true,
+	}
+
+	// The account has no usable password until the user resets it.
+	randomBytes := make([]byte, 32)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	err = user.Password.Set(base64.RawURLEncoding.EncodeToString(randomBytes), app.config.password)
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.models.Users.Insert(user)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
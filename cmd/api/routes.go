@@ -19,6 +19,7 @@ func (app *application) routes() http.Handler {
 	})
 
 	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
+	router.HandlerFunc(http.MethodGet, "/debug/vars", app.requirePermission(permissionUsersAdmin, app.debugVarsHandler))
 
 	router.HandlerFunc(http.MethodGet, "/v1/vehicles", app.listVehiclesHandler)
 	router.HandlerFunc(http.MethodGet, "/v1/vehicles/:id", app.showVehiclesHandler)
@@ -26,13 +27,104 @@ func (app *application) routes() http.Handler {
 	router.HandlerFunc(http.MethodPatch, "/v1/vehicles/:id", app.updateVehiclesHandler)
 	router.HandlerFunc(http.MethodDelete, "/v1/vehicles/:id", app.deleteVehiclesHandler)
 
+	router.HandlerFunc(http.MethodGet, "/v1/documents", app.requirePermission(permissionVehiclesRead, app.listDocumentsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/vehicles/:id/documents", app.requirePermission(permissionVehiclesRead, app.listVehicleDocumentsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/vehicles/:id/documents/:document_id", app.requirePermission(permissionVehiclesRead, app.showVehicleDocumentHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/vehicles/:id/documents", app.requirePermission(permissionVehiclesWrite, app.createVehicleDocumentHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/vehicles/:id/documents/:document_id", app.requirePermission(permissionVehiclesWrite, app.updateVehicleDocumentHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/vehicles/:id/documents/:document_id", app.requirePermission(permissionVehiclesWrite, app.deleteVehicleDocumentHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/vehicles/:id/attachments", app.requirePermission(permissionVehiclesRead, app.listAttachmentsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/vehicles/:id/attachments/:attachment_id", app.requirePermission(permissionVehiclesRead, app.showAttachmentHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/vehicles/:id/attachments/:attachment_id/thumbnails/:size", app.requirePermission(permissionVehiclesRead, app.showThumbnailHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/vehicles/:id/attachments", app.requirePermission(permissionVehiclesWrite, app.createAttachmentHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/vehicles/:id/attachments/:attachment_id", app.requirePermission(permissionVehiclesWrite, app.deleteAttachmentHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/files/:key", app.showFileHandler)
+
+	router.HandlerFunc(http.MethodGet, "/v1/vehicles/:id/comments", app.requirePermission(permissionVehiclesRead, app.listCommentsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/vehicles/:id/comments", app.requirePermission(permissionVehiclesWrite, app.createCommentHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/vehicles/:id/comments/:comment_id", app.requirePermission(permissionVehiclesWrite, app.updateCommentHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/vehicles/:id/comments/:comment_id", app.requirePermission(permissionVehiclesWrite, app.deleteCommentHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/followups", app.requirePermission(permissionFollowupsRead, app.listFollowupsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/followups/:id", app.requirePermission(permissionFollowupsRead, app.showFollowupHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/followups", app.requirePermission(permissionFollowupsWrite, app.createFollowupHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/followups/:id", app.requirePermission(permissionFollowupsWrite, app.updateFollowupHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/followups/:id", app.requirePermission(permissionFollowupsWrite, app.deleteFollowupHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/followups/:id/occurrences", app.requirePermission(permissionFollowupsRead, app.listFollowupOccurrencesHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/followups/:id/transitions", app.requirePermission(permissionFollowupsRead, app.listFollowupTransitionsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/followups/:id/transitions", app.requirePermission(permissionFollowupsWrite, app.createFollowupTransitionHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/followups/:id/calls", app.requirePermission(permissionFollowupsRead, app.listCallLogsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/followups/:id/calls", app.requirePermission(permissionFollowupsWrite, app.createCallLogHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/calls/stats", app.requirePermission(permissionFollowupsRead, app.showCallStatsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/followups/:id/comments", app.requirePermission(permissionFollowupsRead, app.listCommentsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/followups/:id/comments", app.requirePermission(permissionFollowupsWrite, app.createCommentHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/followups/:id/comments/:comment_id", app.requirePermission(permissionFollowupsWrite, app.updateCommentHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/followups/:id/comments/:comment_id", app.requirePermission(permissionFollowupsWrite, app.deleteCommentHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/service-bays", app.requirePermission(permissionFollowupsRead, app.listServiceBaysHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/service-bays/availability", app.requirePermission(permissionFollowupsRead, app.showAvailabilityHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/service-bays", app.requirePermission(permissionUsersAdmin, app.createServiceBayHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/service-bays/:id", app.requirePermission(permissionUsersAdmin, app.deleteServiceBayHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/appointments", app.requirePermission(permissionFollowupsRead, app.listAppointmentsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/appointments/:id", app.requirePermission(permissionFollowupsRead, app.showAppointmentHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/appointments", app.requirePermission(permissionFollowupsWrite, app.createAppointmentHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/appointments/:id", app.requirePermission(permissionFollowupsWrite, app.updateAppointmentHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/appointments/:id", app.requirePermission(permissionFollowupsWrite, app.deleteAppointmentHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/feeds/calendar/:token", app.showCalendarFeedHandler)
+
+	router.HandlerFunc(http.MethodGet, "/v1/organization/schedule", app.requireOrganization(app.showScheduleHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/organization/schedule", app.requirePermission(permissionUsersAdmin, app.requireOrganization(app.updateScheduleHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/organization/holidays", app.requirePermission(permissionUsersAdmin, app.requireOrganization(app.createHolidayHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/organization/holidays/import", app.requirePermission(permissionUsersAdmin, app.requireOrganization(app.importHolidaysHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/organization/holidays/:id", app.requirePermission(permissionUsersAdmin, app.requireOrganization(app.deleteHolidayHandler)))
+
 	// User and Auth
 	router.HandlerFunc(http.MethodPut, "/v1/users/resetpassword", app.resetPasswordHandler)
 	router.HandlerFunc(http.MethodPut, "/v1/users/updatepassword", app.updatePasswordHandler)
 	router.HandlerFunc(http.MethodPost, "/v1/users/register", app.registerUserHandler)
 	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
 	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
-	router.HandlerFunc(http.MethodPut, "/v1/tokens/refresh", app.requireAuthentication(app.refreshTokenHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/activation", app.createActivationTokenHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/tokens/refresh", app.refreshTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/mfa", app.createMFAAuthenticationTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/magiclink", app.createMagicLinkTokenHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/tokens/magiclink", app.exchangeMagicLinkTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/revoke", app.revokeSessionHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/oidc/authorize", app.createOIDCAuthorizationHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/oidc", app.createOIDCAuthenticationTokenHandler)
+
+	router.HandlerFunc(http.MethodPost, "/v1/users/me/mfa/totp", app.denyImpersonation(app.requireActivatedUser(app.enrollTOTPHandler)))
+	router.HandlerFunc(http.MethodPut, "/v1/users/me/mfa/totp", app.denyImpersonation(app.requireActivatedUser(app.confirmTOTPHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/me/mfa/totp", app.denyImpersonation(app.requireActivatedUser(app.disableTOTPHandler)))
+
+	router.HandlerFunc(http.MethodPost, "/v1/users/me/calendar-feed", app.denyImpersonation(app.requireActivatedUser(app.rotateCalendarFeedHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/me/calendar-feed", app.denyImpersonation(app.requireActivatedUser(app.deleteCalendarFeedHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/logins", app.requireActivatedUser(app.listLoginsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/apikeys", app.requireActivatedUser(app.listAPIKeysHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/users/me/apikeys", app.denyImpersonation(app.requireActivatedUser(app.createAPIKeyHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/me/apikeys/:id", app.denyImpersonation(app.requireActivatedUser(app.deleteAPIKeyHandler)))
+
+	// Organizations
+	router.HandlerFunc(http.MethodPost, "/v1/organizations", app.denyImpersonation(app.requireActivatedUser(app.createOrganizationHandler)))
+
+	// Invitations
+	router.HandlerFunc(http.MethodGet, "/v1/invitations", app.requirePermission(permissionUsersAdmin, app.requireOrganization(app.listInvitationsHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/invitations", app.requirePermission(permissionUsersAdmin, app.requireOrganization(app.createInvitationHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/invitations/:id/resend", app.requirePermission(permissionUsersAdmin, app.requireOrganization(app.resendInvitationHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/invitations/:id", app.requirePermission(permissionUsersAdmin, app.requireOrganization(app.deleteInvitationHandler)))
+	router.HandlerFunc(http.MethodPut, "/v1/invitations/accepted", app.acceptInvitationHandler)
+
+	// Admin
+	router.HandlerFunc(http.MethodGet, "/v1/admin/users", app.requirePermission(permissionUsersAdmin, app.listUsersHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/users/:id", app.requirePermission(permissionUsersAdmin, app.showUserHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/admin/users/:id/activation", app.requirePermission(permissionUsersAdmin, app.updateUserActivationHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/users/:id/password-reset", app.denyImpersonation(app.requirePermission(permissionUsersAdmin, app.forcePasswordResetHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/admin/users/:id/tokens", app.denyImpersonation(app.requirePermission(permissionUsersAdmin, app.revokeUserTokensHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/users/:id/impersonation", app.denyImpersonation(app.requirePermission(permissionUsersAdmin, app.createImpersonationHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/impersonation/log", app.requirePermission(permissionUsersAdmin, app.listImpersonationAuditLogHandler))
 
 	standard := alice.New(app.metrics, app.recoverPanic, app.enableCORS, app.rateLimiter, app.authenticate)
 
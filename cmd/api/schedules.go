@@ -0,0 +1,307 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/data"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/ical"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+)
+
+// maxHolidayImportBytes caps the size of an imported holiday calendar.
+const maxHolidayImportBytes = 1_048_576
+
+// maxHolidayEventDays caps the days a single imported event can cover, and
+// maxHolidayImportDays the days a whole import can add, so that a tiny
+// calendar with a huge DURATION can't flood the holidays table.
+const (
+	maxHolidayEventDays  = 366
+	maxHolidayImportDays = 3660
+)
+
+// scheduleFor returns the schedule of the user's organization, or the default
+// schedule if they don't belong to one.
+func (app *application) scheduleFor(user *data.User) (*data.Schedule, error) {
+	if user.OrganizationID == nil {
+		return data.DefaultSchedule(), nil
+	}
+
+	return app.models.Schedules.GetSchedule(*user.OrganizationID)
+}
+
+func (app *application) showScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	orgID := *app.contextGetUser(r).OrganizationID
+
+	org, err := app.models.Schedules.GetOrganization(orgID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	hours, err := app.models.Schedules.GetBusinessHours(orgID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	holidays, err := app.models.Schedules.GetHolidays(orgID, time.Now().AddDate(0, 0, -1))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{
+		"organization":   org,
+		"business_hours": hours,
+		"holidays":       holidays,
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateScheduleHandler changes the organization's time zone and weekly
+// business hours. Days left out of business_hours are closed.
+func (app *application) updateScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	orgID := *app.contextGetUser(r).OrganizationID
+
+	org, err := app.models.Schedules.GetOrganization(orgID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if ok := app.checkVersion(r, org.Version); !ok {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	hours, err := app.models.Schedules.GetBusinessHours(orgID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	var input struct {
+		Timezone      *string               `json:"timezone"`
+		BusinessHours *[]data.BusinessHours `json:"business_hours"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Timezone != nil {
+		org.Timezone = *input.Timezone
+	}
+	if input.BusinessHours != nil {
+		hours = *input.BusinessHours
+	}
+
+	v := validator.New()
+	data.ValidateTimezone(v, org.Timezone)
+	data.ValidateBusinessHours(v, hours)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Schedules.Update(org, hours)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"organization": org, "business_hours": hours}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createHolidayHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Date string `json:"date"`
+		Name string `json:"name"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	holiday := &data.Holiday{
+		OrganizationID: *app.contextGetUser(r).OrganizationID,
+		Date:           input.Date,
+		Name:           input.Name,
+	}
+
+	v := validator.New()
+	if data.ValidateHoliday(v, holiday); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Schedules.InsertHolidays([]*data.Holiday{holiday})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"holiday": holiday}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// importHolidaysHandler adds every day covered by the events of an iCalendar
+// document, such as a public holiday calendar, as a holiday.
+func (app *application) importHolidaysHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	schedule, err := app.scheduleFor(user)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxHolidayImportBytes)
+
+	events, err := ical.Parse(r.Body)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &maxBytesError):
+			app.badRequestResponse(w, r, errors.New("calendar must not be larger than 1MB"))
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+
+	if len(events) == 0 {
+		app.badRequestResponse(w, r, errors.New("calendar must contain at least one event"))
+		return
+	}
+
+	v := validator.New()
+
+	total := 0
+	for _, event := range events {
+		days := eventDays(event)
+		v.Check(days <= maxHolidayEventDays, "calendar", fmt.Sprintf("events must not cover more than %d days", maxHolidayEventDays))
+		total += days
+	}
+	v.Check(total <= maxHolidayImportDays, "calendar", fmt.Sprintf("must not cover more than %d days in total", maxHolidayImportDays))
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var holidays []*data.Holiday
+
+	for _, event := range events {
+		holidays = append(holidays, holidaysForEvent(event, *user.OrganizationID, schedule.Location)...)
+	}
+
+	for _, holiday := range holidays {
+		data.ValidateHoliday(v, holiday)
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Schedules.InsertHolidays(holidays)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"holidays": holidays}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// holidaysForEvent returns a holiday for each day the event covers. All-day
+// events keep their dates; timed events count on their day in loc.
+func holidaysForEvent(event ical.Event, organizationID int64, loc *time.Location) []*data.Holiday {
+	name := event.Summary
+	if name == "" {
+		name = "Holiday"
+	}
+
+	start := event.Start
+	if !event.AllDay {
+		start = start.In(loc)
+	}
+
+	days := min(eventDays(event), maxHolidayEventDays)
+
+	holidays := make([]*data.Holiday, 0, days)
+
+	for i := 0; i < days; i++ {
+		holidays = append(holidays, &data.Holiday{
+			OrganizationID: organizationID,
+			Date:           start.AddDate(0, 0, i).Format(time.DateOnly),
+			Name:           name,
+		})
+	}
+
+	return holidays
+}
+
+// eventDays returns the number of days the event covers, at least one.
+func eventDays(event ical.Event) int {
+	days := event.Duration / (24 * time.Hour)
+	if days < 1 {
+		return 1
+	}
+	if days > math.MaxInt32 {
+		return math.MaxInt32
+	}
+
+	return int(days)
+}
+
+func (app *application) deleteHolidayHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Schedules.DeleteHoliday(int64(id), *app.contextGetUser(r).OrganizationID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "holiday successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -24,6 +24,11 @@ func (app *application) serve() error {
 		WriteTimeout: 30 * time.Second,
 	}
 
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	app.startJobs(jobsCtx)
+
 	shutdownError := make(chan error)
 
 	go func() {
@@ -42,6 +47,7 @@ func (app *application) serve() error {
 		app.logger.PrintInfo("completing background tasks", map[string]string{
 			"addr": server.Addr,
 		})
+		stopJobs()
 		app.wg.Wait()
 		shutdownError <- server.Shutdown(ctx)
 	}()
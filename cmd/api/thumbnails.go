@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/imaging"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/storage"
+	"github.com/julienschmidt/httprouter"
+)
+
+func thumbnailKey(sha256, size string) string {
+	return sha256 + "-" + size
+}
+
+// makeThumbnails stores thumbnails of every size for the content with the
+// given hash and records them on its attachments. Thumbnails already stored,
+// for an earlier upload of the same file, are not made again. Decoding a
+// photo takes a lot of memory, so only -attachments-thumbnail-workers photos
+// are decoded at a time and the other uploads wait their turn.
+func (app *application) makeThumbnails(sha256 string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	missing := false
+	sizes := make([]string, 0, len(imaging.Sizes))
+
+	for _, size := range imaging.Sizes {
+		stored, err := app.storage.Exists(ctx, thumbnailKey(sha256, size.Name))
+		if err != nil {
+			return err
+		}
+
+		missing = missing || !stored
+		sizes = append(sizes, size.Name)
+	}
+
+	if missing {
+		select {
+		case app.thumbnailSlots <- struct{}{}:
+			defer func() { <-app.thumbnailSlots }()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		object, err := app.storage.Get(ctx, sha256)
+		if err != nil {
+			return err
+		}
+
+		b, err := io.ReadAll(io.LimitReader(object, app.config.attachments.maxSize))
+		object.Close()
+		if err != nil {
+			return err
+		}
+
+		thumbnails, err := imaging.Thumbnails(b)
+		if err != nil {
+			return err
+		}
+
+		for size, thumbnail := range thumbnails {
+			err = app.storage.Put(ctx, thumbnailKey(sha256, size), bytes.NewReader(thumbnail), int64(len(thumbnail)), "image/jpeg")
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return app.models.Attachments.SetThumbnails(sha256, sizes)
+}
+
+// showThumbnailHandler serves a thumbnail of an image attachment by size
+// name. Thumbnails never change for a given content, so clients may keep
+// them for as long as they like.
+func (app *application) showThumbnailHandler(w http.ResponseWriter, r *http.Request) {
+	attachment, ok := app.readAttachmentParam(w, r)
+	if !ok {
+		return
+	}
+
+	size := httprouter.ParamsFromContext(r.Context()).ByName("size")
+
+	found := false
+	for _, s := range attachment.Thumbnails {
+		found = found || s == size
+	}
+
+	if !found {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	key := thumbnailKey(attachment.SHA256, size)
+	etag := `"` + key + `"`
+
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	w.Header().Set("ETag", etag)
+
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	object, err := app.storage.Get(r.Context(), key)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			w.Header().Del("Cache-Control")
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer object.Close()
+
+	w.Header().Set("Content-Type", "image/jpeg")
+
+	_, err = io.Copy(w, object)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
@@ -3,10 +3,14 @@ package main
 import (
 	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/PriyanshuSharma23/follow-ups-server/internals/data"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/jwt"
 	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+	"github.com/tomasen/realip"
 )
 
 func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request) {
@@ -24,18 +28,34 @@ func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter,
 	v := validator.New()
 
 	data.ValidateEmail(v, input.Email)
-	data.ValidatePasswordPlaintext(v, input.Password)
+	data.ValidatePasswordPlaintext(v, input.Password, app.config.password)
 
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
+	// Locked and delayed attempts get the same response as a wrong password,
+	// so the throttling gives away nothing about which emails exist.
+	email := strings.ToLower(input.Email)
+	ip := realip.FromRequest(r)
+
+	allowed, err := app.loginAllowed(email, ip)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !allowed {
+		app.invalidCredentialsResponse(w, r)
+		return
+	}
+
 	user, err := app.models.Users.GetByEmail(input.Email)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrNoRecordFound):
-			app.invalidCredentialsResponse(w, r)
+			app.failedLoginResponse(w, r, email, ip, nil)
 		default:
 			app.serverErrorResponse(w, r, err)
 
@@ -50,22 +70,93 @@ func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter,
 	}
 
 	if !matches {
-		app.invalidCredentialsResponse(w, r)
+		app.failedLoginResponse(w, r, email, ip, user)
+		return
+	}
+
+	err = app.models.LoginThrottles.Reset(data.ThrottleAccount, email)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if user.Password.NeedsRehash(app.config.password) {
+		app.rehashPassword(user, input.Password)
+	}
+
+	app.completeLogin(w, r, user, data.LoginMethodPassword)
+}
+
+// rehashPassword upgrades an outdated password hash after a successful login.
+// Failing to do so is logged but does not fail the login.
+func (app *application) rehashPassword(user *data.User, plaintextPassword string) {
+	err := user.Password.Set(plaintextPassword, app.config.password)
+	if err == nil {
+		err = app.models.Users.UpdateUser(user)
+	}
+
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{
+			"user_id": strconv.FormatInt(user.ID, 10),
+		})
+	}
+}
+
+// completeLogin responds to a successful first factor. Users with two-factor
+// authentication get a short-lived mfa token to exchange at /v1/tokens/mfa,
+// everyone else gets a new session straight away.
+func (app *application) completeLogin(w http.ResponseWriter, r *http.Request, user *data.User, method string) {
+	if user.IsDeactivated() {
+		app.deactivatedAccountResponse(w, r)
+		return
+	}
+
+	t, err := app.models.MFA.GetTOTP(user.ID)
+	if err != nil && !errors.Is(err, data.ErrNoRecordFound) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if t != nil && t.Enabled {
+		mfaToken, err := app.models.Tokens.New(user.ID, 5*time.Minute, data.ScopeMFA)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		err = app.writeJSON(w, http.StatusOK, envelope{"mfa_required": true, "mfa_token": mfaToken}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.startSession(w, r, user, method)
+}
+
+// startSession issues tokens for a fully authenticated user and records the
+// login in their history. Deactivated users are refused here as well, since
+// OIDC and the mfa step skip completeLogin.
+func (app *application) startSession(w http.ResponseWriter, r *http.Request, user *data.User, method string) {
+	if user.IsDeactivated() {
+		app.deactivatedAccountResponse(w, r)
 		return
 	}
 
-	token, err := app.models.Tokens.New(user.ID, time.Hour*24, data.ScopeAuthentication)
+	family, err := data.NewTokenFamily()
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	refreshToken, err := app.models.Tokens.New(user.ID, time.Hour*24*30, data.ScopeRefresh)
+	token, refreshToken, err := app.newSessionTokens(user, family)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
+	app.recordLogin(r, user, method, family)
+
 	err = app.writeJSON(w, http.StatusCreated, envelope{"authentication_token": token, "refresh_token": refreshToken}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -89,9 +180,36 @@ func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	user, err := app.models.Users.GetForToken(refreshToken, data.ScopeRefresh)
+	// Access tokens are short-lived in stateless mode, so the refresh token
+	// alone is enough. A caller that is still authenticated must own it, and
+	// the token of another user is left alone.
+	var ownerID int64
+	if caller := app.contextGetUser(r); !caller.IsAnonymous() {
+		ownerID = caller.ID
+	}
+
+	token, err := app.models.Tokens.ConsumeRefresh(refreshToken, ownerID)
 	if err != nil {
-		switch true {
+		switch {
+		case errors.Is(err, data.ErrTokenReused):
+			app.logger.PrintError(errors.New("refresh token reuse detected, revoking token family"), map[string]string{
+				"user_id": strconv.FormatInt(token.UserID, 10),
+				"ip":      realip.FromRequest(r),
+			})
+
+			// Tokens issued before families were introduced have none, so
+			// every session of the user is revoked instead.
+			if token.Family != nil {
+				err = app.models.Tokens.DeleteFamily(token.Family)
+			} else {
+				err = app.revokeSessions(token.UserID)
+			}
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+
+			app.invalidTokenResponse(w, r, "refresh")
 		case errors.Is(err, data.ErrNoRecordFound):
 			app.notFoundResponse(w, r)
 		default:
@@ -100,14 +218,357 @@ func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	token, err := app.models.Tokens.New(user.ID, time.Hour*24, data.ScopeAuthentication)
+	user, err := app.models.Users.Get(token.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.invalidTokenResponse(w, r, "refresh")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if user.IsDeactivated() {
+		app.deactivatedAccountResponse(w, r)
+		return
+	}
+
+	// Refresh tokens issued before families were introduced start a new one.
+	family := token.Family
+	if family == nil {
+		family, err = data.NewTokenFamily()
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	authToken, newRefreshToken, err := app.newSessionTokens(user, family)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"authentication_token": token}, nil)
+	err = app.writeJSON(w, http.StatusCreated, envelope{"authentication_token": authToken, "refresh_token": newRefreshToken}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// revokeSessions deletes the authentication and refresh tokens of the user.
+func (app *application) revokeSessions(userID int64) error {
+	err := app.models.Tokens.DeleteAllForUser(userID, data.ScopeAuthentication)
+	if err != nil {
+		return err
+	}
+
+	return app.models.Tokens.DeleteAllForUser(userID, data.ScopeRefresh)
+}
+
+// newSessionTokens issues an authentication and refresh token pair. Both
+// tokens belong to the given family, which is carried over on every refresh.
+func (app *application) newSessionTokens(user *data.User, family []byte) (*data.Token, *data.Token, error) {
+	token, err := app.newAccessToken(user, family)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refreshToken, err := app.models.Tokens.NewInFamily(user.ID, time.Hour*24*30, data.ScopeRefresh, family)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return token, refreshToken, nil
+}
+
+// accessTokenClaims is everything authenticate needs to build the request
+// user in stateless mode without querying the database.
+type accessTokenClaims struct {
+	jwt.RegisteredClaims
+	OrganizationID *int64           `json:"org,omitempty"`
+	Name           string           `json:"name"`
+	Permissions    data.Permissions `json:"perms"`
+	Activated      bool             `json:"act"`
+}
+
+func (app *application) newAccessToken(user *data.User, family []byte) (*data.Token, error) {
+	if app.config.auth.mode != authModeStateless {
+		return app.models.Tokens.NewInFamily(user.ID, time.Hour*24, data.ScopeAuthentication, family)
+	}
+
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiry := now.Add(app.config.auth.accessTokenTTL)
+
+	claims := accessTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:   accessTokenIssuer,
+			Subject:  strconv.FormatInt(user.ID, 10),
+			IssuedAt: now.Unix(),
+			Expiry:   expiry.Unix(),
+		},
+		OrganizationID: user.OrganizationID,
+		Name:           user.Name,
+		Permissions:    permissions,
+		Activated:      user.Activated,
+	}
+
+	plaintext, err := app.keyring.Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &data.Token{
+		Plaintext: plaintext,
+		Expiry:    expiry,
+		Scope:     data.ScopeAuthentication,
+		UserID:    user.ID,
+	}, nil
+}
+
+const accessTokenIssuer = "follow-ups-server"
+
+// verifyAccessToken checks a stateless access token and returns the user and
+// permissions it carries. The user only has its ID, name, organization and
+// activation status populated.
+func (app *application) verifyAccessToken(token string) (*data.User, data.Permissions, error) {
+	var claims accessTokenClaims
+
+	err := app.keyring.Verify(token, &claims)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if claims.Issuer != accessTokenIssuer {
+		return nil, nil, jwt.ErrInvalidToken
+	}
+
+	id, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return nil, nil, jwt.ErrInvalidToken
+	}
+
+	user := &data.User{
+		ID:             id,
+		Name:           claims.Name,
+		OrganizationID: claims.OrganizationID,
+		Activated:      claims.Activated,
+	}
+
+	return user, claims.Permissions, nil
+}
+
+func (app *application) createActivationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateEmail(v, input.Email); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Every outcome gets the same response so it can't be used to find out
+	// which emails are registered.
+	respond := func() {
+		env := envelope{"message": "if an account with this email needs activation, an email will be sent to it shortly"}
+
+		err := app.writeJSON(w, http.StatusAccepted, env, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+	}
+
+	user, err := app.models.Users.GetByEmail(input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			respond()
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if user.Activated || user.IsDeactivated() {
+		respond()
+		return
+	}
+
+	latest, err := app.models.Tokens.LatestExpiry(user.ID, data.ScopeActivation)
+	switch {
+	case err == nil:
+		if time.Until(latest) > activationTokenTTL-activationResendInterval {
+			respond()
+			return
+		}
+	case !errors.Is(err, data.ErrNoRecordFound):
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Tokens.DeleteAllForUser(user.ID, data.ScopeActivation)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	token, err := app.models.Tokens.New(user.ID, activationTokenTTL, data.ScopeActivation)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.background(func() {
+		data := map[string]interface{}{
+			"userID":          user.ID,
+			"activationToken": token.Plaintext,
+		}
+
+		err := app.mailer.Send(user.Email, "user_welcome.tmpl", data)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{
+				"email": user.Email,
+			})
+		}
+	})
+
+	respond()
+}
+
+const magicLinkTokenTTL = 15 * time.Minute
+
+func (app *application) createMagicLinkTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateEmail(v, input.Email); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	respond := func() {
+		env := envelope{"message": "if an account with this email exists, a sign in link will be sent to it shortly"}
+
+		err := app.writeJSON(w, http.StatusAccepted, env, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+	}
+
+	user, err := app.models.Users.GetByEmail(input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			respond()
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !user.Activated {
+		respond()
+		return
+	}
+
+	// At most one link a minute, the previous link stays valid meanwhile.
+	latest, err := app.models.Tokens.LatestExpiry(user.ID, data.ScopeMagicLink)
+	switch {
+	case err == nil:
+		if time.Until(latest) > magicLinkTokenTTL-time.Minute {
+			respond()
+			return
+		}
+	case !errors.Is(err, data.ErrNoRecordFound):
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	token, err := app.models.Tokens.New(user.ID, magicLinkTokenTTL, data.ScopeMagicLink)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.background(func() {
+		data := map[string]interface{}{
+			"magicLinkToken": token.Plaintext,
+		}
+
+		err := app.mailer.Send(user.Email, "magic_link.tmpl", data)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{
+				"email": user.Email,
+			})
+		}
+	})
+
+	respond()
+}
+
+func (app *application) exchangeMagicLinkTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TokenPlaintext string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidatePlaintextToken(v, input.TokenPlaintext); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Links are single use: consuming the token in one statement means two
+	// requests racing with the same link can't both sign in.
+	userID, err := app.models.Tokens.Consume(input.TokenPlaintext, data.ScopeMagicLink)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.invalidTokenResponse(w, r, "magic link")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Using a link makes any other outstanding links void.
+	err = app.models.Tokens.DeleteAllForUser(userID, data.ScopeMagicLink)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	user, err := app.models.Users.Get(userID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.completeLogin(w, r, user, data.LoginMethodMagicLink)
+}
@@ -9,6 +9,14 @@ import (
 	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
 )
 
+const (
+	activationTokenTTL = time.Hour * 24 * 3
+
+	// activationResendInterval is the least time between two activation
+	// emails to the same address.
+	activationResendInterval = 5 * time.Minute
+)
+
 func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Request) {
 	var inp struct {
 		Name     string `json:"name"`
@@ -29,14 +37,14 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		Activated: false,
 	}
 
-	err = user.Password.Set(inp.Password)
+	err = user.Password.Set(inp.Password, app.config.password)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
 	var v = validator.New()
-	data.ValidateUser(v, user)
+	data.ValidateUser(v, user, app.passwordPolicy)
 
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
@@ -55,7 +63,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	token, err := app.models.Tokens.New(user.ID, time.Hour*24*3, data.ScopeActivation)
+	token, err := app.models.Tokens.New(user.ID, activationTokenTTL, data.ScopeActivation)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -110,6 +118,11 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if user.IsDeactivated() {
+		app.deactivatedAccountResponse(w, r)
+		return
+	}
+
 	user.Activated = true
 
 	err = app.models.Users.UpdateUser(user)
@@ -213,10 +226,10 @@ func (app *application) updatePasswordHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	user.Password.Set(input.Password)
+	user.Password.Set(input.Password, app.config.password)
 
 	var v = validator.New()
-	data.ValidateUser(v, user)
+	data.ValidateUser(v, user, app.passwordPolicy)
 
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
@@ -9,6 +9,11 @@ import (
 	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
 )
 
+const (
+	permissionVehiclesRead  = "vehicles:read"
+	permissionVehiclesWrite = "vehicles:write"
+)
+
 func (app *application) createVehiclesHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		LicensePlate string `json:"license_plate"`
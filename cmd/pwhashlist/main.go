@@ -0,0 +1,65 @@
+// Command pwhashlist converts a newline separated list of passwords, such as
+// a breached password dump, into the sorted hash file read by the API's
+// -password-breach-file flag.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/passwordpolicy"
+)
+
+func main() {
+	in := flag.String("in", "", "input file with one password per line")
+	out := flag.String("out", "breached.bin", "output hash file")
+	flag.Parse()
+
+	if *in == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(*in)
+	if err != nil {
+		fatal(err)
+	}
+	defer f.Close()
+
+	var passwords []string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			passwords = append(passwords, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		fatal(err)
+	}
+
+	list := passwordpolicy.NewHashList(passwords)
+
+	w, err := os.Create(*out)
+	if err != nil {
+		fatal(err)
+	}
+
+	_, err = list.WriteTo(w)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		fatal(err)
+	}
+
+	fmt.Printf("wrote %d hashes to %s\n", list.Len(), *out)
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -9,6 +9,8 @@ require (
 	golang.org/x/crypto v0.21.0
 )
 
+require golang.org/x/sys v0.18.0 // indirect
+
 require (
 	github.com/felixge/httpsnoop v1.0.4
 	github.com/justinas/alice v1.2.0
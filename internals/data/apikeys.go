@@ -0,0 +1,225 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+	"github.com/lib/pq"
+)
+
+// API keys look like fuk_<prefix>_<secret>. The prefix is stored in clear so
+// users can tell their keys apart, only the hash of the full key is kept.
+const apiKeyMarker = "fuk_"
+
+type APIKey struct {
+	CreatedAt  time.Time   `json:"created_at"`
+	LastUsedAt *time.Time  `json:"last_used_at"`
+	Name       string      `json:"name"`
+	Prefix     string      `json:"prefix"`
+	Plaintext  string      `json:"key,omitempty"`
+	Scopes     Permissions `json:"scopes"`
+	Hash       []byte      `json:"-"`
+	ID         int64       `json:"id"`
+	UserID     int64       `json:"-"`
+}
+
+func generateAPIKey(userID int64, name string, scopes Permissions) (*APIKey, error) {
+	randomBytes := make([]byte, 21)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	key := APIKey{
+		UserID: userID,
+		Name:   name,
+		Scopes: scopes,
+		Prefix: apiKeyMarker + encoding.EncodeToString(randomBytes[:5]),
+	}
+
+	key.Plaintext = key.Prefix + "_" + encoding.EncodeToString(randomBytes[5:])
+
+	hash := sha256.Sum256([]byte(key.Plaintext))
+	key.Hash = hash[:]
+
+	return &key, nil
+}
+
+func ValidateAPIKey(v *validator.Validator, key *APIKey) {
+	v.Check(validator.NotBlank(key.Name), "name", "must be provided")
+	v.Check(validator.MaxChars(key.Name, 100), "name", "must not be more than 100 characters long")
+	v.Check(validator.Unique(key.Scopes), "scopes", "must not contain duplicate values")
+}
+
+func ValidatePlaintextAPIKey(v *validator.Validator, plaintextKey string) {
+	v.Check(plaintextKey != "", "key", "must be provided")
+	v.Check(strings.HasPrefix(plaintextKey, apiKeyMarker), "key", "must be a valid api key")
+	v.Check(len(plaintextKey) == 39, "key", "must be 39 bytes long")
+}
+
+type APIKeyModel struct {
+	DB *sql.DB
+}
+
+func (m APIKeyModel) New(userID int64, name string, scopes Permissions) (*APIKey, error) {
+	key, err := generateAPIKey(userID, name, scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.Insert(key)
+	return key, err
+}
+
+func (m APIKeyModel) Insert(key *APIKey) error {
+	stmt := `
+          INSERT INTO api_keys (user_id, name, prefix, hash, scopes)
+          VALUES ($1, $2, $3, $4, $5)
+          RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{key.UserID, key.Name, key.Prefix, key.Hash, pq.Array([]string(key.Scopes))}
+	return m.DB.QueryRowContext(ctx, stmt, args...).Scan(&key.ID, &key.CreatedAt)
+}
+
+func (m APIKeyModel) GetForPlaintext(plaintextKey string) (*APIKey, error) {
+	stmt := `
+          SELECT id, user_id, name, prefix, hash, scopes, created_at, last_used_at
+          FROM api_keys
+          WHERE hash = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	hash := sha256.Sum256([]byte(plaintextKey))
+
+	var key APIKey
+	err := m.DB.QueryRowContext(ctx, stmt, hash[:]).Scan(
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		&key.Hash,
+		pq.Array((*[]string)(&key.Scopes)),
+		&key.CreatedAt,
+		&key.LastUsedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordFound
+		}
+
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+func (m APIKeyModel) GetAllForUser(userID int64) ([]*APIKey, error) {
+	stmt := `
+          SELECT id, user_id, name, prefix, hash, scopes, created_at, last_used_at
+          FROM api_keys
+          WHERE user_id = $1
+          ORDER BY created_at DESC, id DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]*APIKey, 0)
+
+	for rows.Next() {
+		var key APIKey
+
+		err := rows.Scan(
+			&key.ID,
+			&key.UserID,
+			&key.Name,
+			&key.Prefix,
+			&key.Hash,
+			pq.Array((*[]string)(&key.Scopes)),
+			&key.CreatedAt,
+			&key.LastUsedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, &key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// Touch records that the key was just used. Writes are skipped if the key was
+// already used within the last minute to keep busy integrations cheap.
+func (m APIKeyModel) Touch(id int64) error {
+	stmt := `
+          UPDATE api_keys
+          SET last_used_at = NOW()
+          WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, id)
+	return err
+}
+
+func (m APIKeyModel) Delete(id, userID int64) error {
+	stmt := `
+          DELETE FROM api_keys
+          WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoRecordFound
+	}
+
+	return nil
+}
+
+func (m APIKeyModel) DeleteAllForUser(userID int64) error {
+	stmt := `
+          DELETE FROM api_keys
+          WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, userID)
+	return err
+}
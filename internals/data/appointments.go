@@ -0,0 +1,423 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+)
+
+// maxAppointmentLength is the longest a single booking may hold a bay.
+const maxAppointmentLength = 12 * time.Hour
+
+var (
+	ErrBayUnavailable    = errors.New("service bay unavailable")
+	ErrUnknownServiceBay = errors.New("unknown service bay")
+	ErrUnknownFollowup   = errors.New("unknown followup")
+	ErrDuplicateBayName  = errors.New("duplicate service bay name")
+)
+
+type ServiceBay struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Version   int       `json:"version"`
+}
+
+func ValidateServiceBay(v *validator.Validator, bay *ServiceBay) {
+	v.Check(validator.NotBlank(bay.Name), "name", "must be provided")
+	v.Check(validator.MaxChars(bay.Name, 100), "name", "must not be more than 100 characters long")
+}
+
+// Appointment books a service bay for a vehicle from StartsAt until EndsAt.
+// A bay holds one vehicle at a time, which the database enforces.
+type Appointment struct {
+	ID           int64     `json:"id"`
+	VehicleID    int64     `json:"vehicle_id"`
+	ServiceBayID int64     `json:"service_bay_id"`
+	FollowupID   *int64    `json:"followup_id"`
+	StartsAt     time.Time `json:"starts_at"`
+	EndsAt       time.Time `json:"ends_at"`
+	Notes        string    `json:"notes"`
+	CreatedAt    time.Time `json:"created_at"`
+	Version      int       `json:"version"`
+}
+
+func ValidateAppointment(v *validator.Validator, a *Appointment) {
+	v.Check(a.VehicleID > 0, "vehicle_id", "must be provided")
+	v.Check(a.ServiceBayID > 0, "service_bay_id", "must be provided")
+
+	v.Check(!a.StartsAt.IsZero(), "starts_at", "must be provided")
+	v.Check(a.EndsAt.After(a.StartsAt), "duration_minutes", "must be greater than zero")
+	v.Check(a.EndsAt.Sub(a.StartsAt) <= maxAppointmentLength, "duration_minutes", "must not be more than 12 hours")
+
+	v.Check(validator.MaxChars(a.Notes, 10_000), "notes", "must not be more than 10000 characters long")
+}
+
+// Slot is a free period of a service bay.
+type Slot struct {
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+}
+
+// FreeSlots returns the slots of the given length, starting every step from
+// opens, that end by closes and overlap none of the booked appointments.
+func FreeSlots(opens, closes time.Time, length, step time.Duration, booked []*Appointment) []Slot {
+	slots := make([]Slot, 0)
+
+	for start := opens; !start.Add(length).After(closes); start = start.Add(step) {
+		end := start.Add(length)
+
+		free := true
+		for _, a := range booked {
+			if a.StartsAt.Before(end) && a.EndsAt.After(start) {
+				free = false
+				break
+			}
+		}
+
+		if free {
+			slots = append(slots, Slot{StartsAt: start, EndsAt: end})
+		}
+	}
+
+	return slots
+}
+
+type ServiceBayModel struct {
+	DB *sql.DB
+}
+
+func (m ServiceBayModel) Insert(bay *ServiceBay) error {
+	stmt := `INSERT INTO service_bays (name)
+          VALUES ($1)
+          RETURNING id, created_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, stmt, bay.Name).Scan(&bay.ID, &bay.CreatedAt, &bay.Version)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "service_bays_name_key"):
+			return ErrDuplicateBayName
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m ServiceBayModel) GetAll() ([]*ServiceBay, error) {
+	stmt := `SELECT id, name, created_at, version
+           FROM service_bays
+           ORDER BY name, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bays := make([]*ServiceBay, 0)
+
+	for rows.Next() {
+		var bay ServiceBay
+
+		err := rows.Scan(&bay.ID, &bay.Name, &bay.CreatedAt, &bay.Version)
+		if err != nil {
+			return nil, err
+		}
+
+		bays = append(bays, &bay)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return bays, nil
+}
+
+func (m ServiceBayModel) Delete(id int64) error {
+	stmt := `DELETE FROM service_bays
+           WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoRecordFound
+	}
+
+	return nil
+}
+
+type AppointmentModel struct {
+	DB *sql.DB
+}
+
+func (m AppointmentModel) Insert(a *Appointment) error {
+	stmt := `INSERT INTO appointments (vehicle_id, service_bay_id, followup_id, starts_at, ends_at, notes)
+          VALUES ($1, $2, $3, $4, $5, $6)
+          RETURNING id, created_at, version`
+
+	args := []any{a.VehicleID, a.ServiceBayID, a.FollowupID, a.StartsAt, a.EndsAt, a.Notes}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, stmt, args...).Scan(&a.ID, &a.CreatedAt, &a.Version)
+	if err != nil {
+		return appointmentConstraintError(err)
+	}
+
+	return nil
+}
+
+func (m AppointmentModel) Get(id int64) (*Appointment, error) {
+	if id < 1 {
+		return nil, ErrNoRecordFound
+	}
+
+	stmt := `SELECT id, vehicle_id, service_bay_id, followup_id, starts_at, ends_at, notes, created_at, version
+           FROM appointments
+           WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var a Appointment
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(
+		&a.ID,
+		&a.VehicleID,
+		&a.ServiceBayID,
+		&a.FollowupID,
+		&a.StartsAt,
+		&a.EndsAt,
+		&a.Notes,
+		&a.CreatedAt,
+		&a.Version,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordFound
+		}
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+func (m AppointmentModel) Update(a *Appointment) error {
+	stmt := `UPDATE appointments
+           SET vehicle_id = $1, service_bay_id = $2, followup_id = $3, starts_at = $4, ends_at = $5, notes = $6, version = version + 1
+           WHERE id = $7 AND version = $8
+           RETURNING version`
+
+	args := []any{a.VehicleID, a.ServiceBayID, a.FollowupID, a.StartsAt, a.EndsAt, a.Notes, a.ID, a.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, stmt, args...).Scan(&a.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return appointmentConstraintError(err)
+		}
+	}
+
+	return nil
+}
+
+func (m AppointmentModel) Delete(id int64) error {
+	stmt := `DELETE FROM appointments
+           WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoRecordFound
+	}
+
+	return nil
+}
+
+// AppointmentFilters narrows down GetAll. Zero values match every
+// appointment.
+type AppointmentFilters struct {
+	From         *time.Time
+	To           *time.Time
+	ServiceBayID int64
+	FollowupID   int64
+	VehicleID    int64
+	Filters
+}
+
+func (m AppointmentModel) GetAll(f AppointmentFilters) ([]*Appointment, Metadata, error) {
+	stmt := fmt.Sprintf(`SELECT COUNT(*) OVER(), id, vehicle_id, service_bay_id, followup_id, starts_at, ends_at, notes, created_at, version
+           FROM appointments
+           WHERE ($1::timestamptz IS NULL OR ends_at > $1)
+           AND ($2::timestamptz IS NULL OR starts_at < $2)
+           AND (service_bay_id = $3 OR $3 = 0)
+           AND (followup_id = $4 OR $4 = 0)
+           AND (vehicle_id = $5 OR $5 = 0)
+		   ORDER BY %s %s, id ASC
+		   LIMIT $6 OFFSET $7`, f.sortColumn(), f.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{f.From, f.To, f.ServiceBayID, f.FollowupID, f.VehicleID, f.limit(), f.offset()}
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var totalRecords int
+
+	appointments := make([]*Appointment, 0)
+
+	for rows.Next() {
+		var a Appointment
+
+		err := rows.Scan(
+			&totalRecords,
+			&a.ID,
+			&a.VehicleID,
+			&a.ServiceBayID,
+			&a.FollowupID,
+			&a.StartsAt,
+			&a.EndsAt,
+			&a.Notes,
+			&a.CreatedAt,
+			&a.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		appointments = append(appointments, &a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, f.Page, f.PageSize)
+
+	return appointments, metadata, nil
+}
+
+// GetAllBetween returns every appointment overlapping the period.
+func (m AppointmentModel) GetAllBetween(from, to time.Time) ([]*Appointment, error) {
+	stmt := `SELECT id, vehicle_id, service_bay_id, followup_id, starts_at, ends_at, notes, created_at, version
+           FROM appointments
+           WHERE tstzrange(starts_at, ends_at) && tstzrange($1, $2)
+           ORDER BY starts_at`
+
+	return m.query(stmt, from, to)
+}
+
+// GetAllForAssignee returns the appointments for follow-ups assigned to the
+// user that end after since.
+func (m AppointmentModel) GetAllForAssignee(userID int64, since time.Time) ([]*Appointment, error) {
+	stmt := `SELECT appointments.id, appointments.vehicle_id, appointments.service_bay_id, appointments.followup_id,
+           appointments.starts_at, appointments.ends_at, appointments.notes, appointments.created_at, appointments.version
+           FROM appointments
+           INNER JOIN followups ON followups.id = appointments.followup_id
+           WHERE followups.assigned_to = $1 AND appointments.ends_at >= $2
+           ORDER BY appointments.starts_at`
+
+	return m.query(stmt, userID, since)
+}
+
+func (m AppointmentModel) query(stmt string, args ...any) ([]*Appointment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	appointments := make([]*Appointment, 0)
+
+	for rows.Next() {
+		var a Appointment
+
+		err := rows.Scan(
+			&a.ID,
+			&a.VehicleID,
+			&a.ServiceBayID,
+			&a.FollowupID,
+			&a.StartsAt,
+			&a.EndsAt,
+			&a.Notes,
+			&a.CreatedAt,
+			&a.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		appointments = append(appointments, &a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return appointments, nil
+}
+
+func appointmentConstraintError(err error) error {
+	switch {
+	case strings.Contains(err.Error(), "appointments_no_overlap"):
+		return ErrBayUnavailable
+	case strings.Contains(err.Error(), "appointments_vehicle_id_fkey"):
+		return ErrUnknownVehicle
+	case strings.Contains(err.Error(), "appointments_service_bay_id_fkey"):
+		return ErrUnknownServiceBay
+	case strings.Contains(err.Error(), "appointments_followup_id_fkey"):
+		return ErrUnknownFollowup
+	default:
+		return err
+	}
+}
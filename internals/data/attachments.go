@@ -0,0 +1,339 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+	"github.com/lib/pq"
+)
+
+var ErrDuplicateAttachment = errors.New("duplicate attachment")
+
+// AttachmentContentTypes are the file types that may be attached to a
+// vehicle: photos and scanned documents.
+var AttachmentContentTypes = []string{"image/jpeg", "image/png", "image/webp", "application/pdf"}
+
+// Attachment is a file uploaded for a vehicle. Files are stored once per
+// content, under their SHA-256 hash, however many vehicles they are attached
+// to. Stored content is tracked in attachment_blobs and removed by a
+// background job once no attachment refers to it. Thumbnails lists the
+// thumbnail sizes made so far for JPEG and PNG images; WebP images have none.
+// URL is a signed download link, set only in responses.
+type Attachment struct {
+	ID          int64      `json:"id"`
+	VehicleID   int64      `json:"vehicle_id"`
+	UploadedBy  *int64     `json:"uploaded_by"`
+	Filename    string     `json:"filename"`
+	ContentType string     `json:"content_type"`
+	Size        int64      `json:"size"`
+	SHA256      string     `json:"sha256"`
+	Thumbnails  []string   `json:"thumbnails"`
+	CreatedAt   time.Time  `json:"created_at"`
+	URL         string     `json:"url,omitempty"`
+	URLExpiry   *time.Time `json:"url_expiry,omitempty"`
+}
+
+func ValidateAttachment(v *validator.Validator, a *Attachment, maxSize int64) {
+	v.Check(validator.NotBlank(a.Filename), "file", "must have a file name")
+	v.Check(validator.MaxChars(a.Filename, 255), "file", "must have a file name of at most 255 characters")
+	v.Check(a.Size > 0, "file", "must not be empty")
+	v.Check(a.Size <= maxSize, "file", fmt.Sprintf("must not be larger than %d bytes", maxSize))
+	v.Check(validator.In(a.ContentType, AttachmentContentTypes...), "file", "must be a JPEG, PNG or WebP image or a PDF document")
+}
+
+type AttachmentModel struct {
+	DB *sql.DB
+}
+
+func (m AttachmentModel) Insert(a *Attachment) error {
+	stmt := `INSERT INTO vehicle_attachments (vehicle_id, uploaded_by, filename, content_type, size, sha256)
+          VALUES ($1, $2, $3, $4, $5, decode($6, 'hex'))
+          RETURNING id, thumbnails, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{a.VehicleID, a.UploadedBy, a.Filename, a.ContentType, a.Size, a.SHA256}
+
+	err := m.DB.QueryRowContext(ctx, stmt, args...).Scan(&a.ID, pq.Array(&a.Thumbnails), &a.CreatedAt)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "vehicle_attachments_vehicle_sha256_idx"):
+			return ErrDuplicateAttachment
+		case strings.Contains(err.Error(), "vehicle_attachments_vehicle_id_fkey"):
+			return ErrUnknownVehicle
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m AttachmentModel) Get(id, vehicleID int64) (*Attachment, error) {
+	stmt := `SELECT id, vehicle_id, uploaded_by, filename, content_type, size, encode(sha256, 'hex'), thumbnails, created_at
+           FROM vehicle_attachments
+           WHERE id = $1 AND vehicle_id = $2`
+
+	return m.get(stmt, id, vehicleID)
+}
+
+// GetForHash returns the vehicle's attachment with the given content, so that
+// uploading the same file twice gives back the first upload.
+func (m AttachmentModel) GetForHash(vehicleID int64, sha256 string) (*Attachment, error) {
+	stmt := `SELECT id, vehicle_id, uploaded_by, filename, content_type, size, encode(sha256, 'hex'), thumbnails, created_at
+           FROM vehicle_attachments
+           WHERE vehicle_id = $1 AND sha256 = decode($2, 'hex')`
+
+	return m.get(stmt, vehicleID, sha256)
+}
+
+func (m AttachmentModel) get(stmt string, args ...any) (*Attachment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var a Attachment
+
+	err := m.DB.QueryRowContext(ctx, stmt, args...).Scan(
+		&a.ID,
+		&a.VehicleID,
+		&a.UploadedBy,
+		&a.Filename,
+		&a.ContentType,
+		&a.Size,
+		&a.SHA256,
+		pq.Array(&a.Thumbnails),
+		&a.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordFound
+		}
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+// SetThumbnails records the thumbnail sizes made for the content with the
+// given hash, on every attachment that shares it.
+func (m AttachmentModel) SetThumbnails(sha256 string, sizes []string) error {
+	stmt := `UPDATE vehicle_attachments
+           SET thumbnails = $2
+           WHERE sha256 = decode($1, 'hex')`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, sha256, pq.Array(sizes))
+	return err
+}
+
+// Delete removes the attachment. Its stored content is left for
+// SweepOrphanedBlobs, as other attachments may still share it.
+func (m AttachmentModel) Delete(id, vehicleID int64) error {
+	stmt := `DELETE FROM vehicle_attachments
+           WHERE id = $1 AND vehicle_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, stmt, id, vehicleID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoRecordFound
+	}
+
+	return nil
+}
+
+// ClaimBlob records that the content with the given hash is about to be
+// attached, clearing any orphan mark. It must be called before checking
+// whether the content is already stored: it waits for a sweep that is
+// removing the content, and keeps later sweeps away until the content has
+// been orphaned again.
+func (m AttachmentModel) ClaimBlob(sha256 string) error {
+	stmt := `INSERT INTO attachment_blobs (sha256)
+           VALUES (decode($1, 'hex'))
+           ON CONFLICT (sha256) DO UPDATE SET orphaned_at = NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, sha256)
+	return err
+}
+
+// MarkOrphanedBlobs marks the content no attachment refers to anymore, after
+// attachments or whole vehicles were deleted, and returns how many were
+// marked.
+func (m AttachmentModel) MarkOrphanedBlobs(ctx context.Context) (int64, error) {
+	stmt := `UPDATE attachment_blobs b
+           SET orphaned_at = NOW()
+           WHERE orphaned_at IS NULL
+           AND NOT EXISTS (SELECT 1 FROM vehicle_attachments a WHERE a.sha256 = b.sha256)`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, stmt)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// SweepOrphanedBlobs forgets at most limit blobs that were marked orphaned
+// before the given time and are still unreferenced, calling remove for each
+// to delete it from storage. The blobs stay locked until they are forgotten,
+// so an upload of the same content waits for the sweep and then stores it
+// again. Blobs remove fails for are kept for the next sweep. It returns how
+// many blobs were forgotten.
+func (m AttachmentModel) SweepOrphanedBlobs(ctx context.Context, before time.Time, limit int, remove func(ctx context.Context, sha256 string) error) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt := `SELECT encode(sha256, 'hex')
+           FROM attachment_blobs b
+           WHERE orphaned_at < $1
+           AND NOT EXISTS (SELECT 1 FROM vehicle_attachments a WHERE a.sha256 = b.sha256)
+           LIMIT $2
+           FOR UPDATE SKIP LOCKED`
+
+	rows, err := tx.QueryContext(ctx, stmt, before, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var hashes []string
+
+	for rows.Next() {
+		var sha256 string
+
+		err := rows.Scan(&sha256)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		hashes = append(hashes, sha256)
+	}
+
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	removed := make([]string, 0, len(hashes))
+	var removeErr error
+
+	for _, sha256 := range hashes {
+		err := remove(ctx, sha256)
+		if err != nil {
+			removeErr = errors.Join(removeErr, err)
+			continue
+		}
+
+		removed = append(removed, sha256)
+	}
+
+	stmt = `DELETE FROM attachment_blobs
+           WHERE sha256 IN (SELECT decode(h, 'hex') FROM unnest($1::text[]) AS h)`
+
+	result, err := tx.ExecContext(ctx, stmt, pq.Array(removed))
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return n, removeErr
+}
+
+// AttachmentFilters narrows down GetAll. ContentType matches either a full
+// type or, as in "image/", a prefix of one.
+type AttachmentFilters struct {
+	VehicleID   int64
+	ContentType string
+	Filters
+}
+
+func (m AttachmentModel) GetAll(f AttachmentFilters) ([]*Attachment, Metadata, error) {
+	stmt := fmt.Sprintf(`SELECT COUNT(*) OVER(), id, vehicle_id, uploaded_by, filename, content_type, size, encode(sha256, 'hex'), thumbnails, created_at
+           FROM vehicle_attachments
+           WHERE vehicle_id = $1
+           AND (starts_with(content_type, $2) OR $2 = '')
+		   ORDER BY %s %s, id ASC
+		   LIMIT $3 OFFSET $4`, f.sortColumn(), f.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, f.VehicleID, f.ContentType, f.limit(), f.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var totalRecords int
+
+	attachments := make([]*Attachment, 0)
+
+	for rows.Next() {
+		var a Attachment
+
+		err := rows.Scan(
+			&totalRecords,
+			&a.ID,
+			&a.VehicleID,
+			&a.UploadedBy,
+			&a.Filename,
+			&a.ContentType,
+			&a.Size,
+			&a.SHA256,
+			pq.Array(&a.Thumbnails),
+			&a.CreatedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		attachments = append(attachments, &a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, f.Page, f.PageSize)
+
+	return attachments, metadata, nil
+}
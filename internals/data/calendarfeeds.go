@@ -0,0 +1,82 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const scopeCalendarFeed = "calendar-feed"
+
+// CalendarFeedModel stores the token that authenticates a user's calendar
+// feed. Calendar apps can't send headers, so the token goes in the feed URL
+// and never expires; rotating it is the only way to revoke a leaked URL.
+type CalendarFeedModel struct {
+	DB *sql.DB
+}
+
+// Rotate issues a new feed token for the user, replacing any previous one.
+func (m CalendarFeedModel) Rotate(userID int64) (*Token, error) {
+	token, err := generateToken(userID, 0, scopeCalendarFeed)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := `
+          INSERT INTO calendar_feeds (user_id, token_hash)
+          VALUES ($1, $2)
+          ON CONFLICT (user_id) DO UPDATE
+          SET token_hash = EXCLUDED.token_hash, created_at = NOW()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err = m.DB.ExecContext(ctx, stmt, userID, token.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// GetUserIDForToken returns the owner of the feed. Feeds of deactivated
+// users are not found.
+func (m CalendarFeedModel) GetUserIDForToken(tokenPlaintext string) (int64, error) {
+	stmt := `
+          SELECT calendar_feeds.user_id
+          FROM calendar_feeds
+          INNER JOIN users ON users.id = calendar_feeds.user_id
+          WHERE calendar_feeds.token_hash = $1 AND users.activated`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	var userID int64
+
+	err := m.DB.QueryRowContext(ctx, stmt, tokenHash[:]).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecordFound
+		}
+
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+func (m CalendarFeedModel) Delete(userID int64) error {
+	stmt := `
+          DELETE FROM calendar_feeds
+          WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, userID)
+	return err
+}
@@ -0,0 +1,286 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+)
+
+const (
+	CallInbound  = "inbound"
+	CallOutbound = "outbound"
+)
+
+const (
+	CallNoAnswer      = "no_answer"
+	CallBusy          = "busy"
+	CallBackLater     = "call_back_later"
+	CallBooked        = "booked"
+	CallNotInterested = "not_interested"
+)
+
+var callOutcomes = []string{CallNoAnswer, CallBusy, CallBackLater, CallBooked, CallNotInterested}
+
+var phoneRx = regexp.MustCompile(`^\+?[0-9][0-9 ()-]{4,18}[0-9]$`)
+
+// CallLog records a phone call made for a follow-up. There are no customer
+// records of their own, the customer is the owner of the follow-up's vehicle,
+// so calls are linked to that vehicle as well. Duration is in seconds.
+type CallLog struct {
+	ID         int64      `json:"id"`
+	FollowupID int64      `json:"followup_id"`
+	VehicleID  int64      `json:"vehicle_id"`
+	AdvisorID  *int64     `json:"advisor_id"`
+	Direction  string     `json:"direction"`
+	Number     string     `json:"number"`
+	Duration   int        `json:"duration"`
+	Outcome    string     `json:"outcome"`
+	NextCallAt *time.Time `json:"next_call_at"`
+	Notes      string     `json:"notes"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// ValidateCallLog checks the call made for the follow-up. A call back later
+// outcome needs the time to call back, and reschedules the follow-up, so it
+// is not allowed on follow-ups that are already closed.
+func ValidateCallLog(v *validator.Validator, call *CallLog, followup *Followup) {
+	v.Check(validator.In(call.Direction, CallInbound, CallOutbound), "direction", "must be inbound or outbound")
+
+	v.Check(validator.NotBlank(call.Number), "number", "must be provided")
+	v.Check(call.Number == "" || validator.Matches(call.Number, phoneRx), "number", "must be a valid phone number")
+
+	v.Check(validator.Min(call.Duration, 0), "duration", "must be greater than or equal to 0")
+	v.Check(validator.Max(call.Duration, 86400), "duration", "must be less than or equal to 86400")
+
+	v.Check(validator.In(call.Outcome, callOutcomes...), "outcome", "must be one of no_answer, busy, call_back_later, booked or not_interested")
+
+	if call.NextCallAt != nil {
+		v.Check(call.NextCallAt.After(time.Now()), "next_call_at", "must be in the future")
+	}
+
+	if call.Outcome == CallBackLater {
+		v.Check(call.NextCallAt != nil, "next_call_at", "must be provided when the outcome is call_back_later")
+		v.Check(len(followupTransitions[followup.Status]) > 0, "outcome", fmt.Sprintf("can not call back on a %s follow-up", followup.Status))
+	}
+
+	v.Check(validator.MaxChars(call.Notes, 2000), "notes", "must not be more than 2000 characters long")
+}
+
+// CallStats sums up an advisor's calls over a period. BookingRate is the
+// share of calls that ended in a booking.
+type CallStats struct {
+	AdvisorID       int64          `json:"advisor_id"`
+	AdvisorName     string         `json:"advisor_name"`
+	Calls           int            `json:"calls"`
+	Inbound         int            `json:"inbound"`
+	Outbound        int            `json:"outbound"`
+	TotalDuration   int            `json:"total_duration"`
+	AverageDuration int            `json:"average_duration"`
+	Outcomes        map[string]int `json:"outcomes"`
+	BookingRate     float64        `json:"booking_rate"`
+}
+
+// summarize works out the average duration, in whole seconds, and the
+// booking rate from the totals.
+func (s *CallStats) summarize() {
+	if s.Calls == 0 {
+		s.AverageDuration = 0
+		s.BookingRate = 0
+		return
+	}
+
+	s.AverageDuration = s.TotalDuration / s.Calls
+	s.BookingRate = float64(s.Outcomes[CallBooked]) / float64(s.Calls)
+}
+
+type CallLogModel struct {
+	DB *sql.DB
+}
+
+// Insert saves the call. When a follow-up is given, as it is for call back
+// later outcomes, it is saved with it, as long as it is still at the version
+// it was read with.
+func (m CallLogModel) Insert(call *CallLog, reschedule *Followup) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO call_logs (followup_id, vehicle_id, advisor_id, direction, number, duration, outcome, next_call_at, notes)
+          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+          RETURNING id, created_at`
+
+	args := []any{
+		call.FollowupID,
+		call.VehicleID,
+		call.AdvisorID,
+		call.Direction,
+		call.Number,
+		call.Duration,
+		call.Outcome,
+		call.NextCallAt,
+		call.Notes,
+	}
+
+	err = tx.QueryRowContext(ctx, stmt, args...).Scan(&call.ID, &call.CreatedAt)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "call_logs_followup_id_fkey"):
+			return ErrUnknownFollowup
+		default:
+			return err
+		}
+	}
+
+	if reschedule != nil {
+		err = updateFollowup(ctx, tx, reschedule)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m CallLogModel) GetAllForFollowup(followupID int64, f Filters) ([]*CallLog, Metadata, error) {
+	stmt := fmt.Sprintf(`SELECT COUNT(*) OVER(), id, followup_id, vehicle_id, advisor_id, direction, number, duration, outcome, next_call_at, notes, created_at
+           FROM call_logs
+           WHERE followup_id = $1
+		   ORDER BY %s %s, id ASC
+		   LIMIT $2 OFFSET $3`, f.sortColumn(), f.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, followupID, f.limit(), f.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var totalRecords int
+
+	calls := make([]*CallLog, 0)
+
+	for rows.Next() {
+		var call CallLog
+
+		err := rows.Scan(
+			&totalRecords,
+			&call.ID,
+			&call.FollowupID,
+			&call.VehicleID,
+			&call.AdvisorID,
+			&call.Direction,
+			&call.Number,
+			&call.Duration,
+			&call.Outcome,
+			&call.NextCallAt,
+			&call.Notes,
+			&call.CreatedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		calls = append(calls, &call)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, f.Page, f.PageSize)
+
+	return calls, metadata, nil
+}
+
+// CallStatsFilters narrows down GetStats to calls made in [From, To) by
+// advisors of the organization, or by the user themselves when they don't
+// belong to one. AdvisorID, when set, keeps a single advisor.
+type CallStatsFilters struct {
+	OrganizationID int64
+	UserID         int64
+	AdvisorID      int64
+	From           time.Time
+	To             time.Time
+}
+
+// GetStats returns the call statistics of each advisor, busiest first.
+func (m CallLogModel) GetStats(f CallStatsFilters) ([]*CallStats, error) {
+	stmt := `SELECT u.id, u.name, COUNT(*),
+             COUNT(*) FILTER (WHERE c.direction = 'inbound'),
+             COUNT(*) FILTER (WHERE c.direction = 'outbound'),
+             COALESCE(SUM(c.duration), 0),
+             COUNT(*) FILTER (WHERE c.outcome = 'no_answer'),
+             COUNT(*) FILTER (WHERE c.outcome = 'busy'),
+             COUNT(*) FILTER (WHERE c.outcome = 'call_back_later'),
+             COUNT(*) FILTER (WHERE c.outcome = 'booked'),
+             COUNT(*) FILTER (WHERE c.outcome = 'not_interested')
+           FROM call_logs c
+           INNER JOIN users u ON u.id = c.advisor_id
+           WHERE (u.organization_id = $1 OR u.id = $2)
+           AND (u.id = $3 OR $3 = 0)
+           AND c.created_at >= $4 AND c.created_at < $5
+           GROUP BY u.id, u.name
+           ORDER BY COUNT(*) DESC, u.id ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, f.OrganizationID, f.UserID, f.AdvisorID, f.From, f.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := make([]*CallStats, 0)
+
+	for rows.Next() {
+		var s CallStats
+		var noAnswer, busy, callBackLater, booked, notInterested int
+
+		err := rows.Scan(
+			&s.AdvisorID,
+			&s.AdvisorName,
+			&s.Calls,
+			&s.Inbound,
+			&s.Outbound,
+			&s.TotalDuration,
+			&noAnswer,
+			&busy,
+			&callBackLater,
+			&booked,
+			&notInterested,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		s.Outcomes = map[string]int{
+			CallNoAnswer:      noAnswer,
+			CallBusy:          busy,
+			CallBackLater:     callBackLater,
+			CallBooked:        booked,
+			CallNotInterested: notInterested,
+		}
+		s.summarize()
+
+		stats = append(stats, &s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
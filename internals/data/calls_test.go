@@ -0,0 +1,112 @@
+package data
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+)
+
+func TestValidateCallLog(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+	earlier := time.Now().Add(-time.Hour)
+
+	valid := func() *CallLog {
+		return &CallLog{
+			Direction: CallOutbound,
+			Number:    "+91 98765 43210",
+			Duration:  90,
+			Outcome:   CallNoAnswer,
+		}
+	}
+
+	tests := []struct {
+		name       string
+		modify     func(c *CallLog)
+		status     string
+		wantErrors []string
+	}{
+		{"valid", func(c *CallLog) {}, FollowupPending, nil},
+		{"inbound", func(c *CallLog) { c.Direction = CallInbound }, FollowupPending, nil},
+		{"unknown direction", func(c *CallLog) { c.Direction = "sideways" }, FollowupPending, []string{"direction"}},
+		{"missing number", func(c *CallLog) { c.Number = "" }, FollowupPending, []string{"number"}},
+		{"number with letters", func(c *CallLog) { c.Number = "call me" }, FollowupPending, []string{"number"}},
+		{"number too short", func(c *CallLog) { c.Number = "1234" }, FollowupPending, []string{"number"}},
+		{"number with brackets", func(c *CallLog) { c.Number = "+1 (555) 010-9999" }, FollowupPending, nil},
+		{"negative duration", func(c *CallLog) { c.Duration = -1 }, FollowupPending, []string{"duration"}},
+		{"day long duration", func(c *CallLog) { c.Duration = 86400 }, FollowupPending, nil},
+		{"duration over a day", func(c *CallLog) { c.Duration = 86401 }, FollowupPending, []string{"duration"}},
+		{"unknown outcome", func(c *CallLog) { c.Outcome = "hung_up" }, FollowupPending, []string{"outcome"}},
+		{"next call in the past", func(c *CallLog) { c.NextCallAt = &earlier }, FollowupPending, []string{"next_call_at"}},
+		{"notes too long", func(c *CallLog) { c.Notes = strings.Repeat("a", 2001) }, FollowupPending, []string{"notes"}},
+		{
+			"call back later",
+			func(c *CallLog) { c.Outcome = CallBackLater; c.NextCallAt = &later },
+			FollowupContacted, nil,
+		},
+		{
+			"call back later without a time",
+			func(c *CallLog) { c.Outcome = CallBackLater },
+			FollowupPending, []string{"next_call_at"},
+		},
+		{
+			"call back later on a closed follow-up",
+			func(c *CallLog) { c.Outcome = CallBackLater; c.NextCallAt = &later },
+			FollowupCompleted, []string{"outcome"},
+		},
+		{"booked on a closed follow-up", func(c *CallLog) { c.Outcome = CallBooked }, FollowupLost, nil},
+	}
+
+	for _, tt := range tests {
+		call := valid()
+		tt.modify(call)
+
+		v := validator.New()
+		ValidateCallLog(v, call, &Followup{Status: tt.status})
+
+		if len(v.Errors) != len(tt.wantErrors) {
+			t.Errorf("%s: got errors %v, want errors for %v", tt.name, v.Errors, tt.wantErrors)
+			continue
+		}
+
+		for _, key := range tt.wantErrors {
+			if _, ok := v.Errors[key]; !ok {
+				t.Errorf("%s: got errors %v, want one for %s", tt.name, v.Errors, key)
+			}
+		}
+	}
+}
+
+func TestCallStatsSummarize(t *testing.T) {
+	tests := []struct {
+		name        string
+		calls       int
+		duration    int
+		booked      int
+		wantAverage int
+		wantRate    float64
+	}{
+		{"no calls", 0, 0, 0, 0, 0},
+		{"every call booked", 4, 400, 4, 100, 1},
+		{"none booked", 3, 90, 0, 30, 0},
+		{"some booked", 4, 250, 1, 62, 0.25},
+		{"a third booked", 3, 10, 1, 3, 1.0 / 3},
+	}
+
+	for _, tt := range tests {
+		s := &CallStats{
+			Calls:         tt.calls,
+			TotalDuration: tt.duration,
+			Outcomes:      map[string]int{CallBooked: tt.booked, CallNoAnswer: tt.calls - tt.booked},
+		}
+		s.summarize()
+
+		if s.AverageDuration != tt.wantAverage {
+			t.Errorf("%s: AverageDuration = %d, want %d", tt.name, s.AverageDuration, tt.wantAverage)
+		}
+		if s.BookingRate != tt.wantRate {
+			t.Errorf("%s: BookingRate = %v, want %v", tt.name, s.BookingRate, tt.wantRate)
+		}
+	}
+}
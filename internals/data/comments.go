@@ -0,0 +1,227 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+	"github.com/lib/pq"
+)
+
+// mentionRx matches @mentions, which name users by email address as in
+// "@asha@example.com". The @ must not follow a word character, so email
+// addresses written out in the body are not taken for mentions.
+var mentionRx = regexp.MustCompile(`(?:^|[^\w.@])@([A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)+)`)
+
+// CommentThread names what comments are about, either a vehicle or a
+// follow-up.
+type CommentThread struct {
+	VehicleID  int64
+	FollowupID int64
+}
+
+// Comment is a markdown note on a vehicle or follow-up. Mentions holds the
+// ids of the users mentioned in the body.
+type Comment struct {
+	ID         int64      `json:"id"`
+	VehicleID  *int64     `json:"vehicle_id,omitempty"`
+	FollowupID *int64     `json:"followup_id,omitempty"`
+	AuthorID   *int64     `json:"author_id"`
+	AuthorName string     `json:"author_name"`
+	Body       string     `json:"body"`
+	Mentions   []int64    `json:"mentions"`
+	CreatedAt  time.Time  `json:"created_at"`
+	EditedAt   *time.Time `json:"edited_at"`
+	Version    int        `json:"version"`
+}
+
+func ValidateComment(v *validator.Validator, c *Comment) {
+	v.Check(validator.NotBlank(c.Body), "body", "must be provided")
+	v.Check(validator.MaxChars(c.Body, 10000), "body", "must not be more than 10000 characters long")
+}
+
+// MentionedEmails returns the email addresses mentioned in the comment, in
+// lower case and without duplicates.
+func (c *Comment) MentionedEmails() []string {
+	emails := []string{}
+	seen := make(map[string]bool)
+
+	for _, match := range mentionRx.FindAllStringSubmatch(c.Body, -1) {
+		email := strings.ToLower(match[1])
+
+		if !seen[email] {
+			seen[email] = true
+			emails = append(emails, email)
+		}
+	}
+
+	return emails
+}
+
+type CommentModel struct {
+	DB *sql.DB
+}
+
+func (m CommentModel) Insert(c *Comment) error {
+	stmt := `INSERT INTO comments (vehicle_id, followup_id, author_id, body, mentions)
+          VALUES ($1, $2, $3, $4, $5)
+          RETURNING id, created_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{c.VehicleID, c.FollowupID, c.AuthorID, c.Body, pq.Array(c.Mentions)}
+
+	err := m.DB.QueryRowContext(ctx, stmt, args...).Scan(&c.ID, &c.CreatedAt, &c.Version)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "comments_vehicle_id_fkey"):
+			return ErrUnknownVehicle
+		case strings.Contains(err.Error(), "comments_followup_id_fkey"):
+			return ErrUnknownFollowup
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m CommentModel) Get(id int64, t CommentThread) (*Comment, error) {
+	stmt := `SELECT c.id, c.vehicle_id, c.followup_id, c.author_id, COALESCE(u.name, ''), c.body, c.mentions, c.created_at, c.edited_at, c.version
+           FROM comments c
+           LEFT JOIN users u ON u.id = c.author_id
+           WHERE c.id = $1 AND (c.vehicle_id = $2 OR c.followup_id = $3)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var c Comment
+
+	err := m.DB.QueryRowContext(ctx, stmt, id, t.VehicleID, t.FollowupID).Scan(
+		&c.ID,
+		&c.VehicleID,
+		&c.FollowupID,
+		&c.AuthorID,
+		&c.AuthorName,
+		&c.Body,
+		pq.Array(&c.Mentions),
+		&c.CreatedAt,
+		&c.EditedAt,
+		&c.Version,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordFound
+		}
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func (m CommentModel) Update(c *Comment) error {
+	stmt := `UPDATE comments
+           SET body = $1, mentions = $2, edited_at = NOW(), version = version + 1
+           WHERE id = $3 AND version = $4
+           RETURNING edited_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{c.Body, pq.Array(c.Mentions), c.ID, c.Version}
+
+	err := m.DB.QueryRowContext(ctx, stmt, args...).Scan(&c.EditedAt, &c.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m CommentModel) Delete(id int64) error {
+	stmt := `DELETE FROM comments
+           WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoRecordFound
+	}
+
+	return nil
+}
+
+func (m CommentModel) GetAll(t CommentThread, f Filters) ([]*Comment, Metadata, error) {
+	stmt := fmt.Sprintf(`SELECT COUNT(*) OVER(), c.id, c.vehicle_id, c.followup_id, c.author_id, COALESCE(u.name, ''), c.body, c.mentions, c.created_at, c.edited_at, c.version
+           FROM comments c
+           LEFT JOIN users u ON u.id = c.author_id
+           WHERE (c.vehicle_id = $1 OR c.followup_id = $2)
+		   ORDER BY c.%s %s, c.id ASC
+		   LIMIT $3 OFFSET $4`, f.sortColumn(), f.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, t.VehicleID, t.FollowupID, f.limit(), f.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var totalRecords int
+
+	comments := make([]*Comment, 0)
+
+	for rows.Next() {
+		var c Comment
+
+		err := rows.Scan(
+			&totalRecords,
+			&c.ID,
+			&c.VehicleID,
+			&c.FollowupID,
+			&c.AuthorID,
+			&c.AuthorName,
+			&c.Body,
+			pq.Array(&c.Mentions),
+			&c.CreatedAt,
+			&c.EditedAt,
+			&c.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		comments = append(comments, &c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, f.Page, f.PageSize)
+
+	return comments, metadata, nil
+}
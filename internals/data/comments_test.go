@@ -0,0 +1,41 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMentionedEmails(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"none", "Called the customer, no answer.", []string{}},
+		{"start of body", "@asha@example.com please call back", []string{"asha@example.com"}},
+		{"after a space", "thanks @ravi@example.com", []string{"ravi@example.com"}},
+		{"after a newline", "done\n@ravi@example.com", []string{"ravi@example.com"}},
+		{"in parentheses", "(@ravi@example.com)", []string{"ravi@example.com"}},
+		{"lower cased", "@Asha@Example.COM", []string{"asha@example.com"}},
+		{"duplicates", "@asha@example.com and @ASHA@example.com", []string{"asha@example.com"}},
+		{"in order of appearance", "@ravi@example.com @asha@example.com", []string{"ravi@example.com", "asha@example.com"}},
+		{"trailing full stop", "ask @asha@example.com.", []string{"asha@example.com"}},
+		{"trailing comma", "@asha@example.com, @ravi@example.com", []string{"asha@example.com", "ravi@example.com"}},
+		{"plus and dots", "@asha.k+shop@mail.example.co.in", []string{"asha.k+shop@mail.example.co.in"}},
+		{"plain email address", "wrote to asha@example.com", []string{}},
+		{"after a word character", "x@asha@example.com", []string{}},
+		{"after a dot", "end.@asha@example.com", []string{}},
+		{"after another @", "@@asha@example.com", []string{}},
+		{"domain without a dot", "@asha@localhost", []string{}},
+		{"missing domain", "@asha@", []string{}},
+		{"bare at sign", "meet @ 5pm", []string{}},
+	}
+
+	for _, tt := range tests {
+		c := &Comment{Body: tt.body}
+
+		if got := c.MentionedEmails(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: MentionedEmails(%q) = %q, want %q", tt.name, tt.body, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+)
+
+const (
+	DocumentInsurance    = "insurance"
+	DocumentPUC          = "puc"
+	DocumentRegistration = "registration"
+	DocumentWarranty     = "warranty"
+)
+
+var documentTitles = map[string]string{
+	DocumentInsurance:    "insurance policy",
+	DocumentPUC:          "PUC certificate",
+	DocumentRegistration: "registration certificate",
+	DocumentWarranty:     "extended warranty",
+}
+
+// VehicleDocument is an expiring document carried by a vehicle. FollowupID
+// points to the follow-up that reminds the team to get it renewed.
+type VehicleDocument struct {
+	ID         int64     `json:"id"`
+	VehicleID  int64     `json:"vehicle_id"`
+	Type       string    `json:"type"`
+	Number     string    `json:"number"`
+	Issuer     string    `json:"issuer"`
+	ValidFrom  string    `json:"valid_from"`
+	ValidUntil string    `json:"valid_until"`
+	FollowupID *int64    `json:"followup_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	Version    int       `json:"version"`
+}
+
+func ValidateVehicleDocument(v *validator.Validator, doc *VehicleDocument) {
+	_, known := documentTitles[doc.Type]
+	v.Check(doc.Type != "", "type", "must be provided")
+	v.Check(doc.Type == "" || known, "type", "must be one of insurance, puc, registration or warranty")
+
+	v.Check(validator.NotBlank(doc.Number), "number", "must be provided")
+	v.Check(validator.MaxChars(doc.Number, 100), "number", "must not be more than 100 characters long")
+	v.Check(validator.MaxChars(doc.Issuer, 200), "issuer", "must not be more than 200 characters long")
+
+	from, err := time.Parse(time.DateOnly, doc.ValidFrom)
+	v.Check(err == nil, "valid_from", "must be a date in the YYYY-MM-DD format")
+
+	until, err := time.Parse(time.DateOnly, doc.ValidUntil)
+	v.Check(err == nil, "valid_until", "must be a date in the YYYY-MM-DD format")
+
+	if v.Valid() {
+		v.Check(!until.Before(from), "valid_until", "must not be before valid_from")
+	}
+}
+
+// RenewalFollowup returns a follow-up to renew the document, due lead before
+// the document expires but no earlier than now, at the schedule's next
+// business moment.
+func (doc *VehicleDocument) RenewalFollowup(lead time.Duration, schedule *Schedule) (*Followup, error) {
+	until, err := time.ParseInLocation(time.DateOnly, doc.ValidUntil, schedule.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	due := until.Add(-lead)
+	if now := time.Now(); due.Before(now) {
+		due = now
+	}
+
+	return &Followup{
+		VehicleID: doc.VehicleID,
+		Title:     fmt.Sprintf("Renew %s %s", documentTitles[doc.Type], doc.Number),
+		Notes:     fmt.Sprintf("The %s %s expires on %s.", documentTitles[doc.Type], doc.Number, doc.ValidUntil),
+		DueAt:     schedule.NextBusinessMoment(due),
+	}, nil
+}
+
+type VehicleDocumentModel struct {
+	DB *sql.DB
+}
+
+// Insert saves the document together with its renewal follow-up.
+func (m VehicleDocumentModel) Insert(doc *VehicleDocument, followup *Followup) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = insertFollowup(ctx, tx, followup)
+	if err != nil {
+		return err
+	}
+
+	doc.FollowupID = &followup.ID
+
+	stmt := `INSERT INTO vehicle_documents (vehicle_id, type, number, issuer, valid_from, valid_until, followup_id)
+          VALUES ($1, $2, $3, $4, $5, $6, $7)
+          RETURNING id, created_at, version`
+
+	args := []any{doc.VehicleID, doc.Type, doc.Number, doc.Issuer, doc.ValidFrom, doc.ValidUntil, doc.FollowupID}
+
+	err = tx.QueryRowContext(ctx, stmt, args...).Scan(&doc.ID, &doc.CreatedAt, &doc.Version)
+	if err != nil {
+		return documentConstraintError(err)
+	}
+
+	return tx.Commit()
+}
+
+func (m VehicleDocumentModel) Get(id, vehicleID int64) (*VehicleDocument, error) {
+	stmt := `SELECT id, vehicle_id, type, number, issuer, to_char(valid_from, 'YYYY-MM-DD'), to_char(valid_until, 'YYYY-MM-DD'), followup_id, created_at, version
+           FROM vehicle_documents
+           WHERE id = $1 AND vehicle_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var doc VehicleDocument
+
+	err := m.DB.QueryRowContext(ctx, stmt, id, vehicleID).Scan(
+		&doc.ID,
+		&doc.VehicleID,
+		&doc.Type,
+		&doc.Number,
+		&doc.Issuer,
+		&doc.ValidFrom,
+		&doc.ValidUntil,
+		&doc.FollowupID,
+		&doc.CreatedAt,
+		&doc.Version,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordFound
+		}
+		return nil, err
+	}
+
+	return &doc, nil
+}
+
+// Update saves the document. When a renewal follow-up is given, as it is
+// when the expiry date changes, it is created and linked to the document, and
+// the previous renewal follow-up, if still open, is cancelled on behalf of
+// userID.
+func (m VehicleDocumentModel) Update(doc *VehicleDocument, followup *Followup, userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if followup != nil {
+		if doc.FollowupID != nil {
+			err = cancelRenewalFollowup(ctx, tx, *doc.FollowupID, userID, "document expiry date changed")
+			if err != nil {
+				return err
+			}
+		}
+
+		err = insertFollowup(ctx, tx, followup)
+		if err != nil {
+			return err
+		}
+
+		doc.FollowupID = &followup.ID
+	}
+
+	stmt := `UPDATE vehicle_documents
+           SET type = $1, number = $2, issuer = $3, valid_from = $4, valid_until = $5, followup_id = $6, version = version + 1
+           WHERE id = $7 AND version = $8
+           RETURNING version`
+
+	args := []any{doc.Type, doc.Number, doc.Issuer, doc.ValidFrom, doc.ValidUntil, doc.FollowupID, doc.ID, doc.Version}
+
+	err = tx.QueryRowContext(ctx, stmt, args...).Scan(&doc.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return documentConstraintError(err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// cancelRenewalFollowup cancels a renewal follow-up that is no longer needed,
+// recording the transition with the reason. Follow-ups that already reached a
+// final status are left alone.
+func cancelRenewalFollowup(ctx context.Context, tx *sql.Tx, followupID, userID int64, reason string) error {
+	stmt := `
+          SELECT status
+          FROM followups
+          WHERE id = $1
+          FOR UPDATE`
+
+	var status string
+
+	err := tx.QueryRowContext(ctx, stmt, followupID).Scan(&status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+
+		return err
+	}
+
+	if !validator.In(FollowupCancelled, followupTransitions[status]...) {
+		return nil
+	}
+
+	stmt = `
+          UPDATE followups
+          SET status = $1, status_reason = $2, version = version + 1
+          WHERE id = $3`
+
+	_, err = tx.ExecContext(ctx, stmt, FollowupCancelled, reason, followupID)
+	if err != nil {
+		return err
+	}
+
+	stmt = `
+          INSERT INTO followup_transitions (followup_id, from_status, to_status, reason, user_id)
+          VALUES ($1, $2, $3, $4, $5)`
+
+	_, err = tx.ExecContext(ctx, stmt, followupID, status, FollowupCancelled, reason, userID)
+	return err
+}
+
+// Delete deletes the document and cancels its renewal follow-up, if still
+// open, on behalf of userID.
+func (m VehicleDocumentModel) Delete(id, vehicleID, userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `DELETE FROM vehicle_documents
+           WHERE id = $1 AND vehicle_id = $2
+           RETURNING followup_id`
+
+	var followupID *int64
+
+	err = tx.QueryRowContext(ctx, stmt, id, vehicleID).Scan(&followupID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecordFound
+		}
+
+		return err
+	}
+
+	if followupID != nil {
+		err = cancelRenewalFollowup(ctx, tx, *followupID, userID, "document deleted")
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DocumentFilters narrows down GetAll. Zero values match every document;
+// ExpiringWithin keeps documents that expire within that many days from
+// today, including those already expired.
+type DocumentFilters struct {
+	VehicleID      int64
+	Type           string
+	ExpiringWithin *int
+	Filters
+}
+
+func (m VehicleDocumentModel) GetAll(f DocumentFilters) ([]*VehicleDocument, Metadata, error) {
+	stmt := fmt.Sprintf(`SELECT COUNT(*) OVER(), id, vehicle_id, type, number, issuer, to_char(valid_from, 'YYYY-MM-DD'), to_char(valid_until, 'YYYY-MM-DD'), followup_id, created_at, version
+           FROM vehicle_documents
+           WHERE (vehicle_id = $1 OR $1 = 0)
+           AND (type = $2 OR $2 = '')
+           AND ($3::integer IS NULL OR valid_until <= CURRENT_DATE + $3::integer)
+		   ORDER BY %s %s, id ASC
+		   LIMIT $4 OFFSET $5`, f.sortColumn(), f.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{f.VehicleID, f.Type, f.ExpiringWithin, f.limit(), f.offset()}
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var totalRecords int
+
+	docs := make([]*VehicleDocument, 0)
+
+	for rows.Next() {
+		var doc VehicleDocument
+
+		err := rows.Scan(
+			&totalRecords,
+			&doc.ID,
+			&doc.VehicleID,
+			&doc.Type,
+			&doc.Number,
+			&doc.Issuer,
+			&doc.ValidFrom,
+			&doc.ValidUntil,
+			&doc.FollowupID,
+			&doc.CreatedAt,
+			&doc.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		docs = append(docs, &doc)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, f.Page, f.PageSize)
+
+	return docs, metadata, nil
+}
+
+func documentConstraintError(err error) error {
+	switch {
+	case strings.Contains(err.Error(), "vehicle_documents_vehicle_id_fkey"):
+		return ErrUnknownVehicle
+	default:
+		return err
+	}
+}
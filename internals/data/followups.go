@@ -0,0 +1,530 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/rrule"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+)
+
+const (
+	FollowupPending   = "pending"
+	FollowupContacted = "contacted"
+	FollowupBooked    = "booked"
+	FollowupCompleted = "completed"
+	FollowupLost      = "lost"
+	FollowupCancelled = "cancelled"
+)
+
+// followupTransitions is the follow-up state machine: the statuses each
+// status may move to. Completed, lost and cancelled follow-ups are final.
+var followupTransitions = map[string][]string{
+	FollowupPending:   {FollowupContacted, FollowupBooked, FollowupLost, FollowupCancelled},
+	FollowupContacted: {FollowupBooked, FollowupLost, FollowupCancelled},
+	FollowupBooked:    {FollowupCompleted, FollowupLost, FollowupCancelled},
+	FollowupCompleted: {},
+	FollowupLost:      {},
+	FollowupCancelled: {},
+}
+
+var (
+	ErrUnknownVehicle  = errors.New("unknown vehicle")
+	ErrUnknownAssignee = errors.New("unknown assignee")
+)
+
+// Followup is a task due at DueAt. A follow-up with a Recurrence is one
+// occurrence of a series starting at RecurrenceStart; completing it creates
+// the next occurrence, which points back to it through PreviousID.
+type Followup struct {
+	ID              int64      `json:"id"`
+	VehicleID       int64      `json:"vehicle_id"`
+	AssignedTo      *int64     `json:"assigned_to"`
+	Title           string     `json:"title"`
+	Notes           string     `json:"notes"`
+	DueAt           time.Time  `json:"due_at"`
+	Status          string     `json:"status"`
+	StatusReason    string     `json:"status_reason,omitempty"`
+	Recurrence      string     `json:"recurrence,omitempty"`
+	RecurrenceStart *time.Time `json:"recurrence_start,omitempty"`
+	PreviousID      *int64     `json:"previous_id,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	Version         int        `json:"version"`
+}
+
+// Occurrences returns up to n occurrences of the follow-up's series after its
+// current due date, each moved to the schedule's next business moment.
+func (f *Followup) Occurrences(n int, schedule *Schedule) ([]time.Time, error) {
+	rule, err := rrule.Parse(f.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	start := f.DueAt
+	if f.RecurrenceStart != nil {
+		start = *f.RecurrenceStart
+	}
+
+	occurrences := rule.After(start, f.DueAt, n)
+	for i := range occurrences {
+		occurrences[i] = schedule.NextBusinessMoment(occurrences[i])
+	}
+
+	return occurrences, nil
+}
+
+// next returns the follow-up for the occurrence after f, or nil if f doesn't
+// recur or was the last occurrence.
+func (f *Followup) next(schedule *Schedule) (*Followup, error) {
+	if f.Recurrence == "" {
+		return nil, nil
+	}
+
+	occurrences, err := f.Occurrences(1, schedule)
+	if err != nil || len(occurrences) == 0 {
+		return nil, err
+	}
+
+	return &Followup{
+		VehicleID:       f.VehicleID,
+		AssignedTo:      f.AssignedTo,
+		Title:           f.Title,
+		Notes:           f.Notes,
+		DueAt:           occurrences[0],
+		Recurrence:      f.Recurrence,
+		RecurrenceStart: f.RecurrenceStart,
+		PreviousID:      &f.ID,
+	}, nil
+}
+
+// FollowupTransition records a status change and who made it.
+type FollowupTransition struct {
+	ID         int64     `json:"id"`
+	FollowupID int64     `json:"followup_id"`
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	Reason     string    `json:"reason,omitempty"`
+	UserID     *int64    `json:"user_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func ValidateFollowup(v *validator.Validator, followup *Followup) {
+	v.Check(followup.VehicleID > 0, "vehicle_id", "must be provided")
+
+	v.Check(validator.NotBlank(followup.Title), "title", "must be provided")
+	v.Check(validator.MaxChars(followup.Title, 500), "title", "must not be more than 500 characters long")
+
+	v.Check(validator.MaxChars(followup.Notes, 10_000), "notes", "must not be more than 10000 characters long")
+
+	v.Check(!followup.DueAt.IsZero(), "due_at", "must be provided")
+
+	if followup.Recurrence != "" {
+		_, err := rrule.Parse(followup.Recurrence)
+		v.Check(err == nil, "recurrence", "must be a valid recurrence rule")
+	}
+}
+
+// ValidateFollowupTransition checks that the follow-up may move to the
+// transition's status. Losing or cancelling a follow-up needs a reason.
+func ValidateFollowupTransition(v *validator.Validator, followup *Followup, t *FollowupTransition) {
+	_, known := followupTransitions[t.To]
+
+	v.Check(t.To != "", "status", "must be provided")
+	v.Check(t.To == "" || known, "status", "must be a valid status")
+
+	if known {
+		v.Check(validator.In(t.To, followupTransitions[followup.Status]...), "status", fmt.Sprintf("can not move from %s to %s", followup.Status, t.To))
+	}
+
+	if t.To == FollowupLost || t.To == FollowupCancelled {
+		v.Check(validator.NotBlank(t.Reason), "reason", "must be provided")
+	}
+	v.Check(validator.MaxChars(t.Reason, 500), "reason", "must not be more than 500 characters long")
+}
+
+type FollowupModel struct {
+	DB *sql.DB
+}
+
+func (m FollowupModel) Insert(followup *Followup) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	return insertFollowup(ctx, m.DB, followup)
+}
+
+// queryRower is satisfied by both *sql.DB and *sql.Tx.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func insertFollowup(ctx context.Context, db queryRower, followup *Followup) error {
+	stmt := `INSERT INTO followups (vehicle_id, assigned_to, title, notes, due_at, recurrence, recurrence_start, previous_id)
+          VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+          RETURNING id, status, created_at, version`
+
+	args := []any{
+		followup.VehicleID,
+		followup.AssignedTo,
+		followup.Title,
+		followup.Notes,
+		followup.DueAt,
+		followup.Recurrence,
+		followup.RecurrenceStart,
+		followup.PreviousID,
+	}
+
+	err := db.QueryRowContext(ctx, stmt, args...).Scan(&followup.ID, &followup.Status, &followup.CreatedAt, &followup.Version)
+	if err != nil {
+		return followupConstraintError(err)
+	}
+
+	return nil
+}
+
+func (m FollowupModel) Get(id int64) (*Followup, error) {
+	if id < 1 {
+		return nil, ErrNoRecordFound
+	}
+
+	stmt := `SELECT id, vehicle_id, assigned_to, title, notes, due_at, status, status_reason, recurrence, recurrence_start, previous_id, created_at, version
+           FROM followups
+           WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	var followup Followup
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(
+		&followup.ID,
+		&followup.VehicleID,
+		&followup.AssignedTo,
+		&followup.Title,
+		&followup.Notes,
+		&followup.DueAt,
+		&followup.Status,
+		&followup.StatusReason,
+		&followup.Recurrence,
+		&followup.RecurrenceStart,
+		&followup.PreviousID,
+		&followup.CreatedAt,
+		&followup.Version,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordFound
+		}
+		return nil, err
+	}
+
+	return &followup, nil
+}
+
+// Update saves everything but the status, which only changes through
+// Transition.
+func (m FollowupModel) Update(followup *Followup) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	return updateFollowup(ctx, m.DB, followup)
+}
+
+// updateFollowup is Update on db, so that other models can change a
+// follow-up within their own transactions.
+func updateFollowup(ctx context.Context, db queryRower, followup *Followup) error {
+	stmt := `UPDATE followups
+           SET vehicle_id = $1, assigned_to = $2, title = $3, notes = $4, due_at = $5, recurrence = $6, recurrence_start = $7, version = version + 1
+           WHERE id = $8 AND version = $9
+           RETURNING version`
+
+	args := []any{
+		followup.VehicleID,
+		followup.AssignedTo,
+		followup.Title,
+		followup.Notes,
+		followup.DueAt,
+		followup.Recurrence,
+		followup.RecurrenceStart,
+		followup.ID,
+		followup.Version,
+	}
+
+	err := db.QueryRowContext(ctx, stmt, args...).Scan(&followup.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return followupConstraintError(err)
+		}
+	}
+
+	return nil
+}
+
+func (m FollowupModel) Delete(id int64) error {
+	stmt := `DELETE FROM followups
+           WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoRecordFound
+	}
+
+	return nil
+}
+
+// FollowupFilters narrows down GetAll. Zero values match every follow-up.
+type FollowupFilters struct {
+	Status     string
+	AssignedTo int64
+	VehicleID  int64
+	Filters
+}
+
+func (m FollowupModel) GetAll(f FollowupFilters) ([]*Followup, Metadata, error) {
+	stmt := fmt.Sprintf(`SELECT COUNT(*) OVER(), id, vehicle_id, assigned_to, title, notes, due_at, status, status_reason, recurrence, recurrence_start, previous_id, created_at, version
+           FROM followups
+           WHERE (status = $1 OR $1 = '')
+           AND (assigned_to = $2 OR $2 = 0)
+           AND (vehicle_id = $3 OR $3 = 0)
+		   ORDER BY %s %s, id ASC
+		   LIMIT $4 OFFSET $5`, f.sortColumn(), f.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{f.Status, f.AssignedTo, f.VehicleID, f.limit(), f.offset()}
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var totalRecords int
+
+	followups := make([]*Followup, 0)
+
+	for rows.Next() {
+		var followup Followup
+
+		err := rows.Scan(
+			&totalRecords,
+			&followup.ID,
+			&followup.VehicleID,
+			&followup.AssignedTo,
+			&followup.Title,
+			&followup.Notes,
+			&followup.DueAt,
+			&followup.Status,
+			&followup.StatusReason,
+			&followup.Recurrence,
+			&followup.RecurrenceStart,
+			&followup.PreviousID,
+			&followup.CreatedAt,
+			&followup.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		followups = append(followups, &followup)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, f.Page, f.PageSize)
+
+	return followups, metadata, nil
+}
+
+// GetAllAssigned returns the follow-ups assigned to the user that are due
+// after since, in due date order.
+func (m FollowupModel) GetAllAssigned(userID int64, since time.Time) ([]*Followup, error) {
+	stmt := `SELECT id, vehicle_id, assigned_to, title, notes, due_at, status, status_reason, recurrence, recurrence_start, previous_id, created_at, version
+           FROM followups
+           WHERE assigned_to = $1 AND due_at >= $2
+           ORDER BY due_at, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	followups := make([]*Followup, 0)
+
+	for rows.Next() {
+		var followup Followup
+
+		err := rows.Scan(
+			&followup.ID,
+			&followup.VehicleID,
+			&followup.AssignedTo,
+			&followup.Title,
+			&followup.Notes,
+			&followup.DueAt,
+			&followup.Status,
+			&followup.StatusReason,
+			&followup.Recurrence,
+			&followup.RecurrenceStart,
+			&followup.PreviousID,
+			&followup.CreatedAt,
+			&followup.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		followups = append(followups, &followup)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return followups, nil
+}
+
+// Transition moves the follow-up to t.To and records the transition, as long
+// as the follow-up is still at the version and status it was read with.
+// Completing a recurring follow-up also creates its next occurrence, due at
+// the schedule's next business moment, which is returned.
+func (m FollowupModel) Transition(followup *Followup, t *FollowupTransition, schedule *Schedule) (*Followup, error) {
+	var next *Followup
+
+	if t.To == FollowupCompleted {
+		var err error
+
+		next, err = followup.next(schedule)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt := `
+          UPDATE followups
+          SET status = $1, status_reason = $2, version = version + 1
+          WHERE id = $3 AND version = $4 AND status = $5
+          RETURNING version`
+
+	args := []any{t.To, t.Reason, followup.ID, followup.Version, followup.Status}
+
+	err = tx.QueryRowContext(ctx, stmt, args...).Scan(&followup.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrEditConflict
+		}
+
+		return nil, err
+	}
+
+	t.FollowupID = followup.ID
+	t.From = followup.Status
+
+	stmt = `
+          INSERT INTO followup_transitions (followup_id, from_status, to_status, reason, user_id)
+          VALUES ($1, $2, $3, $4, $5)
+          RETURNING id, created_at`
+
+	err = tx.QueryRowContext(ctx, stmt, t.FollowupID, t.From, t.To, t.Reason, t.UserID).Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if next != nil {
+		err = insertFollowup(ctx, tx, next)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	followup.Status = t.To
+	followup.StatusReason = t.Reason
+
+	return next, nil
+}
+
+func (m FollowupModel) GetTransitions(followupID int64) ([]*FollowupTransition, error) {
+	stmt := `
+          SELECT id, followup_id, from_status, to_status, reason, user_id, created_at
+          FROM followup_transitions
+          WHERE followup_id = $1
+          ORDER BY created_at, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, followupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transitions := make([]*FollowupTransition, 0)
+
+	for rows.Next() {
+		var t FollowupTransition
+
+		err := rows.Scan(&t.ID, &t.FollowupID, &t.From, &t.To, &t.Reason, &t.UserID, &t.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		transitions = append(transitions, &t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return transitions, nil
+}
+
+func followupConstraintError(err error) error {
+	switch {
+	case strings.Contains(err.Error(), "followups_vehicle_id_fkey"):
+		return ErrUnknownVehicle
+	case strings.Contains(err.Error(), "followups_assigned_to_fkey"):
+		return ErrUnknownAssignee
+	default:
+		return err
+	}
+}
@@ -0,0 +1,195 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+)
+
+const scopeImpersonation = "impersonation"
+
+// ImpersonationSession lets an admin act as another user. Unless Elevated,
+// the session may only read.
+type ImpersonationSession struct {
+	CreatedAt time.Time `json:"created_at"`
+	Expiry    time.Time `json:"expiry"`
+	Reason    string    `json:"reason"`
+	Token     *Token    `json:"-"`
+	ID        int64     `json:"id"`
+	AdminID   int64     `json:"admin_id"`
+	UserID    int64     `json:"user_id"`
+	Elevated  bool      `json:"elevated"`
+}
+
+type ImpersonationAuditEntry struct {
+	CreatedAt time.Time `json:"created_at"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	IP        string    `json:"ip"`
+	ID        int64     `json:"id"`
+	SessionID int64     `json:"session_id"`
+	AdminID   int64     `json:"admin_id"`
+	UserID    int64     `json:"user_id"`
+	Status    int       `json:"status"`
+}
+
+func ValidateImpersonationSession(v *validator.Validator, s *ImpersonationSession) {
+	v.Check(validator.NotBlank(s.Reason), "reason", "must be provided")
+	v.Check(validator.MaxChars(s.Reason, 500), "reason", "must not be more than 500 characters long")
+	v.Check(s.AdminID != s.UserID, "user_id", "you can not impersonate yourself")
+}
+
+type ImpersonationModel struct {
+	DB *sql.DB
+}
+
+// New starts a session and sets its Token to the plaintext bearer token.
+func (m ImpersonationModel) New(s *ImpersonationSession, ttl time.Duration) error {
+	token, err := generateToken(s.UserID, ttl, scopeImpersonation)
+	if err != nil {
+		return err
+	}
+
+	stmt := `
+          INSERT INTO impersonation_sessions (token_hash, admin_id, user_id, reason, elevated, expiry)
+          VALUES ($1, $2, $3, $4, $5, $6)
+          RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{token.Hash, s.AdminID, s.UserID, s.Reason, s.Elevated, token.Expiry}
+
+	err = m.DB.QueryRowContext(ctx, stmt, args...).Scan(&s.ID, &s.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	s.Token = token
+	s.Expiry = token.Expiry
+
+	return nil
+}
+
+func (m ImpersonationModel) GetForToken(tokenPlaintext string) (*ImpersonationSession, error) {
+	stmt := `
+          SELECT id, admin_id, user_id, reason, elevated, created_at, expiry
+          FROM impersonation_sessions
+          WHERE token_hash = $1 AND expiry > $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	var s ImpersonationSession
+	err := m.DB.QueryRowContext(ctx, stmt, tokenHash[:], time.Now()).Scan(
+		&s.ID,
+		&s.AdminID,
+		&s.UserID,
+		&s.Reason,
+		&s.Elevated,
+		&s.CreatedAt,
+		&s.Expiry,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordFound
+		}
+
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func (m ImpersonationModel) InsertAuditEntry(e *ImpersonationAuditEntry) error {
+	stmt := `
+          INSERT INTO impersonation_audit_log (session_id, admin_id, user_id, method, path, status, ip)
+          VALUES ($1, $2, $3, $4, $5, $6, $7)
+          RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{e.SessionID, e.AdminID, e.UserID, e.Method, e.Path, e.Status, e.IP}
+	return m.DB.QueryRowContext(ctx, stmt, args...).Scan(&e.ID, &e.CreatedAt)
+}
+
+// UpdateAuditEntryStatus records the response status of an entry inserted
+// before its request was handled.
+func (m ImpersonationModel) UpdateAuditEntryStatus(e *ImpersonationAuditEntry) error {
+	stmt := `
+          UPDATE impersonation_audit_log
+          SET status = $1
+          WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, e.Status, e.ID)
+	return err
+}
+
+// GetAuditLog lists audit entries, optionally only those of one session.
+// When organizationID is set, only entries about members of that
+// organization, or made by its admins, are listed. Entries outlive the users
+// they mention, so without an organization the whole log is listed.
+func (m ImpersonationModel) GetAuditLog(sessionID int64, organizationID *int64, f Filters) ([]*ImpersonationAuditEntry, Metadata, error) {
+	stmt := fmt.Sprintf(`SELECT COUNT(*) OVER(), l.id, l.session_id, l.admin_id, l.user_id, l.method, l.path, l.status, l.ip, l.created_at
+           FROM impersonation_audit_log l
+           LEFT JOIN users u ON u.id = l.user_id
+           LEFT JOIN users a ON a.id = l.admin_id
+           WHERE (l.session_id = $1 OR $1 = 0)
+           AND ($2::bigint IS NULL OR u.organization_id = $2 OR a.organization_id = $2)
+		   ORDER BY l.%s %s, l.id ASC
+		   LIMIT $3 OFFSET $4`, f.sortColumn(), f.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, sessionID, organizationID, f.limit(), f.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var totalRecords int
+
+	entries := make([]*ImpersonationAuditEntry, 0)
+
+	for rows.Next() {
+		var e ImpersonationAuditEntry
+
+		err := rows.Scan(
+			&totalRecords,
+			&e.ID,
+			&e.SessionID,
+			&e.AdminID,
+			&e.UserID,
+			&e.Method,
+			&e.Path,
+			&e.Status,
+			&e.IP,
+			&e.CreatedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		entries = append(entries, &e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, f.Page, f.PageSize)
+
+	return entries, metadata, nil
+}
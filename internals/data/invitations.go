@@ -0,0 +1,328 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+	"github.com/lib/pq"
+)
+
+const scopeInvitation = "invitation"
+
+const (
+	RoleMember = "member"
+	RoleAdmin  = "admin"
+)
+
+// RolePermissions lists the permissions granted to a user who accepts an
+// invitation with the role. The users:admin permission of an organization
+// admin only reaches the users of their own organization.
+var RolePermissions = map[string]Permissions{
+	RoleMember: {"vehicles:read", "vehicles:write", "followups:read", "followups:write"},
+	RoleAdmin:  {"vehicles:read", "vehicles:write", "followups:read", "followups:write", "users:admin"},
+}
+
+var ErrDuplicateInvitation = errors.New("duplicate invitation")
+
+type Invitation struct {
+	CreatedAt      time.Time  `json:"created_at"`
+	Expiry         time.Time  `json:"expiry"`
+	AcceptedAt     *time.Time `json:"accepted_at"`
+	InvitedBy      *int64     `json:"invited_by"`
+	Token          *Token     `json:"-"`
+	Email          string     `json:"email"`
+	Role           string     `json:"role"`
+	ID             int64      `json:"id"`
+	OrganizationID int64      `json:"organization_id"`
+}
+
+func ValidateInvitation(v *validator.Validator, inv *Invitation) {
+	ValidateEmail(v, inv.Email)
+
+	_, ok := RolePermissions[inv.Role]
+	v.Check(inv.Role != "", "role", "must be provided")
+	v.Check(inv.Role == "" || ok, "role", fmt.Sprintf("must be one of %q or %q", RoleMember, RoleAdmin))
+}
+
+type InvitationModel struct {
+	DB *sql.DB
+}
+
+// New stores the invitation and sets its Token to the plaintext token to be
+// emailed to the invitee.
+func (m InvitationModel) New(inv *Invitation, ttl time.Duration) error {
+	token, err := generateToken(0, ttl, scopeInvitation)
+	if err != nil {
+		return err
+	}
+
+	stmt := `
+          INSERT INTO invitations (organization_id, email, role, token_hash, invited_by, expiry)
+          VALUES ($1, $2, $3, $4, $5, $6)
+          RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{inv.OrganizationID, inv.Email, inv.Role, token.Hash, inv.InvitedBy, token.Expiry}
+
+	err = m.DB.QueryRowContext(ctx, stmt, args...).Scan(&inv.ID, &inv.CreatedAt)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "invitations_pending_email_idx"):
+			return ErrDuplicateInvitation
+		default:
+			return err
+		}
+	}
+
+	inv.Token = token
+	inv.Expiry = token.Expiry
+
+	return nil
+}
+
+// Renew replaces the token of a pending invitation, invalidating the one
+// sent before, and extends its expiry.
+func (m InvitationModel) Renew(inv *Invitation, ttl time.Duration) error {
+	token, err := generateToken(0, ttl, scopeInvitation)
+	if err != nil {
+		return err
+	}
+
+	stmt := `
+          UPDATE invitations
+          SET token_hash = $1, expiry = $2
+          WHERE id = $3 AND accepted_at IS NULL
+          RETURNING expiry`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, stmt, token.Hash, token.Expiry, inv.ID).Scan(&inv.Expiry)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+
+		return err
+	}
+
+	inv.Token = token
+
+	return nil
+}
+
+func (m InvitationModel) Get(id, organizationID int64) (*Invitation, error) {
+	stmt := `
+          SELECT id, organization_id, email, role, invited_by, created_at, expiry, accepted_at
+          FROM invitations
+          WHERE id = $1 AND organization_id = $2`
+
+	return m.getOne(stmt, id, organizationID)
+}
+
+// GetForToken returns the pending, unexpired invitation for the token.
+func (m InvitationModel) GetForToken(tokenPlaintext string) (*Invitation, error) {
+	stmt := `
+          SELECT id, organization_id, email, role, invited_by, created_at, expiry, accepted_at
+          FROM invitations
+          WHERE token_hash = $1 AND accepted_at IS NULL AND expiry > $2`
+
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	return m.getOne(stmt, tokenHash[:], time.Now())
+}
+
+func (m InvitationModel) getOne(stmt string, args ...any) (*Invitation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var inv Invitation
+	err := m.DB.QueryRowContext(ctx, stmt, args...).Scan(
+		&inv.ID,
+		&inv.OrganizationID,
+		&inv.Email,
+		&inv.Role,
+		&inv.InvitedBy,
+		&inv.CreatedAt,
+		&inv.Expiry,
+		&inv.AcceptedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordFound
+		}
+
+		return nil, err
+	}
+
+	return &inv, nil
+}
+
+// GetAllForOrganization lists the organization's invitations. With pending
+// set, accepted invitations are left out.
+func (m InvitationModel) GetAllForOrganization(organizationID int64, pending bool, f Filters) ([]*Invitation, Metadata, error) {
+	stmt := fmt.Sprintf(`SELECT COUNT(*) OVER(), id, organization_id, email, role, invited_by, created_at, expiry, accepted_at
+           FROM invitations
+           WHERE organization_id = $1 AND (accepted_at IS NULL OR NOT $2)
+		   ORDER BY %s %s, id ASC
+		   LIMIT $3 OFFSET $4`, f.sortColumn(), f.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, organizationID, pending, f.limit(), f.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var totalRecords int
+
+	invitations := make([]*Invitation, 0)
+
+	for rows.Next() {
+		var inv Invitation
+
+		err := rows.Scan(
+			&totalRecords,
+			&inv.ID,
+			&inv.OrganizationID,
+			&inv.Email,
+			&inv.Role,
+			&inv.InvitedBy,
+			&inv.CreatedAt,
+			&inv.Expiry,
+			&inv.AcceptedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		invitations = append(invitations, &inv)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, f.Page, f.PageSize)
+
+	return invitations, metadata, nil
+}
+
+// Accept marks a pending invitation as accepted and joins the user to its
+// organization with the permissions of its role, all in one transaction. A
+// user without an ID is created, activated; an existing one is moved into the
+// organization and activated, unless it has been deactivated. It returns
+// ErrNoRecordFound if the invitation was accepted or revoked in the meantime,
+// and ErrEditConflict if the user changed or was deactivated.
+func (m InvitationModel) Accept(inv *Invitation, user *User) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `
+          UPDATE invitations
+          SET accepted_at = NOW()
+          WHERE id = $1 AND accepted_at IS NULL
+          RETURNING accepted_at`
+
+	err = tx.QueryRowContext(ctx, stmt, inv.ID).Scan(&inv.AcceptedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecordFound
+		}
+
+		return err
+	}
+
+	organizationID := inv.OrganizationID
+
+	if user.ID == 0 {
+		stmt = `
+          INSERT INTO users (name, email, password_hash, activated, organization_id)
+          VALUES ($1, $2, $3, true, $4)
+          RETURNING id, created_at, version`
+
+		args := []any{user.Name, user.Email, user.Password.hash, organizationID}
+		err = tx.QueryRowContext(ctx, stmt, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
+	} else {
+		stmt = `
+          UPDATE users
+          SET activated = true, organization_id = $1, version = version + 1
+          WHERE id = $2 AND version = $3 AND deactivated_at IS NULL
+          RETURNING version`
+
+		err = tx.QueryRowContext(ctx, stmt, organizationID, user.ID, user.Version).Scan(&user.Version)
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		case strings.Contains(err.Error(), "users_email_key"):
+			return ErrDuplicateEmail
+		default:
+			return err
+		}
+	}
+
+	stmt = `
+          INSERT INTO users_permissions
+          SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)
+          ON CONFLICT DO NOTHING`
+
+	_, err = tx.ExecContext(ctx, stmt, user.ID, pq.Array(RolePermissions[inv.Role]))
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	user.OrganizationID = &organizationID
+	user.Activated = true
+
+	return nil
+}
+
+// Delete revokes a pending invitation.
+func (m InvitationModel) Delete(id, organizationID int64) error {
+	stmt := `
+          DELETE FROM invitations
+          WHERE id = $1 AND organization_id = $2 AND accepted_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, stmt, id, organizationID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoRecordFound
+	}
+
+	return nil
+}
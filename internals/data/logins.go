@@ -0,0 +1,136 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	LoginMethodPassword  = "password"
+	LoginMethodTOTP      = "totp"
+	LoginMethodMagicLink = "magic-link"
+	LoginMethodOIDC      = "oidc"
+)
+
+type Login struct {
+	CreatedAt   time.Time `json:"created_at"`
+	UserID      *int64    `json:"-"`
+	Email       string    `json:"-"`
+	Method      string    `json:"method"`
+	IP          string    `json:"ip"`
+	UserAgent   string    `json:"user_agent"`
+	Fingerprint []byte    `json:"-"`
+	ID          int64     `json:"id"`
+	Success     bool      `json:"success"`
+}
+
+// DeviceFingerprint identifies a device by its user agent and network. Only
+// the /24 (IPv4) or /48 (IPv6) network is used so that address changes within
+// the same connection don't count as a new device.
+func DeviceFingerprint(ip, userAgent string) []byte {
+	network := ip
+
+	if parsed := net.ParseIP(ip); parsed != nil {
+		if v4 := parsed.To4(); v4 != nil {
+			network = v4.Mask(net.CIDRMask(24, 32)).String()
+		} else {
+			network = parsed.Mask(net.CIDRMask(48, 128)).String()
+		}
+	}
+
+	sum := sha256.Sum256([]byte(network + "|" + userAgent))
+	return sum[:]
+}
+
+type LoginModel struct {
+	DB *sql.DB
+}
+
+func (m LoginModel) Insert(l *Login) error {
+	stmt := `
+          INSERT INTO user_logins (user_id, email, method, ip, user_agent, fingerprint, success)
+          VALUES ($1, $2, $3, $4, $5, $6, $7)
+          RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{l.UserID, l.Email, l.Method, l.IP, l.UserAgent, l.Fingerprint, l.Success}
+	return m.DB.QueryRowContext(ctx, stmt, args...).Scan(&l.ID, &l.CreatedAt)
+}
+
+// DeviceHistory reports whether the user logged in successfully from the
+// device before, and whether they ever logged in successfully at all.
+func (m LoginModel) DeviceHistory(userID int64, fingerprint []byte) (bool, bool, error) {
+	stmt := `
+          SELECT COUNT(*) FILTER (WHERE fingerprint = $2), COUNT(*)
+          FROM user_logins
+          WHERE user_id = $1 AND success`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var fromDevice, total int
+
+	err := m.DB.QueryRowContext(ctx, stmt, userID, fingerprint).Scan(&fromDevice, &total)
+	if err != nil {
+		return false, false, err
+	}
+
+	return fromDevice > 0, total > 0, nil
+}
+
+func (m LoginModel) GetAllForUser(userID int64, f Filters) ([]*Login, Metadata, error) {
+	stmt := fmt.Sprintf(`SELECT COUNT(*) OVER(), id, user_id, email, method, ip, user_agent, fingerprint, success, created_at
+           FROM user_logins
+           WHERE user_id = $1
+		   ORDER BY %s %s, id DESC
+		   LIMIT $2 OFFSET $3`, f.sortColumn(), f.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID, f.limit(), f.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var totalRecords int
+
+	logins := make([]*Login, 0)
+
+	for rows.Next() {
+		var l Login
+
+		err := rows.Scan(
+			&totalRecords,
+			&l.ID,
+			&l.UserID,
+			&l.Email,
+			&l.Method,
+			&l.IP,
+			&l.UserAgent,
+			&l.Fingerprint,
+			&l.Success,
+			&l.CreatedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		logins = append(logins, &l)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, f.Page, f.PageSize)
+
+	return logins, metadata, nil
+}
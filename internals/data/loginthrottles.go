@@ -0,0 +1,116 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Failed logins are counted separately per account and per client IP.
+const (
+	ThrottleAccount = "account"
+	ThrottleIP      = "ip"
+)
+
+type LoginThrottle struct {
+	LastFailedAt time.Time
+	LockedUntil  *time.Time
+	Kind         string
+	Key          string
+	Failures     int
+}
+
+func (t *LoginThrottle) Locked(now time.Time) bool {
+	return t.LockedUntil != nil && now.Before(*t.LockedUntil)
+}
+
+type LoginThrottleModel struct {
+	DB *sql.DB
+}
+
+func (m LoginThrottleModel) Get(kind, key string) (*LoginThrottle, error) {
+	stmt := `
+          SELECT kind, key, failures, last_failed_at, locked_until
+          FROM login_throttles
+          WHERE kind = $1 AND key = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var t LoginThrottle
+	err := m.DB.QueryRowContext(ctx, stmt, kind, key).Scan(
+		&t.Kind,
+		&t.Key,
+		&t.Failures,
+		&t.LastFailedAt,
+		&t.LockedUntil,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordFound
+		}
+
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// RecordFailure counts a failed login. Failures older than window are
+// forgotten, so the count starts over after a quiet period.
+func (m LoginThrottleModel) RecordFailure(kind, key string, window time.Duration) (*LoginThrottle, error) {
+	stmt := `
+          INSERT INTO login_throttles (kind, key, failures, last_failed_at)
+          VALUES ($1, $2, 1, NOW())
+          ON CONFLICT (kind, key) DO UPDATE
+          SET failures = CASE
+                  WHEN login_throttles.last_failed_at < NOW() - $3 * INTERVAL '1 second' THEN 1
+                  ELSE login_throttles.failures + 1
+              END,
+              last_failed_at = NOW()
+          RETURNING kind, key, failures, last_failed_at, locked_until`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var t LoginThrottle
+	err := m.DB.QueryRowContext(ctx, stmt, kind, key, window.Seconds()).Scan(
+		&t.Kind,
+		&t.Key,
+		&t.Failures,
+		&t.LastFailedAt,
+		&t.LockedUntil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// Lock blocks logins until the given time and starts the failure count over.
+func (m LoginThrottleModel) Lock(kind, key string, until time.Time) error {
+	stmt := `
+          UPDATE login_throttles
+          SET locked_until = $3, failures = 0
+          WHERE kind = $1 AND key = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, kind, key, until)
+	return err
+}
+
+func (m LoginThrottleModel) Reset(kind, key string) error {
+	stmt := `
+          DELETE FROM login_throttles
+          WHERE kind = $1 AND key = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, kind, key)
+	return err
+}
@@ -0,0 +1,223 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+)
+
+const recoveryCodeCount = 10
+
+type TOTP struct {
+	CreatedAt    time.Time
+	Secret       string
+	UserID       int64
+	LastUsedStep int64
+	Version      int
+	Enabled      bool
+}
+
+func ValidateOTP(v *validator.Validator, code string) {
+	v.Check(validator.NotBlank(code), "code", "must be provided")
+	v.Check(len(code) == 6, "code", "must be 6 digits long")
+}
+
+// normalizeRecoveryCode lets users type recovery codes with or without the
+// separator and in any case.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ReplaceAll(code, "-", "")
+	code = strings.ReplaceAll(code, " ", "")
+	return strings.ToLower(code)
+}
+
+type MFAModel struct {
+	DB *sql.DB
+}
+
+func (m MFAModel) GetTOTP(userID int64) (*TOTP, error) {
+	stmt := `
+          SELECT user_id, secret, enabled, last_used_step, created_at, version
+          FROM users_totp
+          WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var t TOTP
+	err := m.DB.QueryRowContext(ctx, stmt, userID).Scan(
+		&t.UserID,
+		&t.Secret,
+		&t.Enabled,
+		&t.LastUsedStep,
+		&t.CreatedAt,
+		&t.Version,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordFound
+		}
+
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// EnrollTOTP stores a new, not yet enabled, secret for the user. It returns
+// ErrEditConflict if two-factor authentication is already enabled.
+func (m MFAModel) EnrollTOTP(userID int64, secret string) (*TOTP, error) {
+	stmt := `
+          INSERT INTO users_totp (user_id, secret)
+          VALUES ($1, $2)
+          ON CONFLICT (user_id) DO UPDATE
+          SET secret = EXCLUDED.secret, last_used_step = 0, created_at = NOW(), version = users_totp.version + 1
+          WHERE users_totp.enabled = false
+          RETURNING created_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	t := TOTP{UserID: userID, Secret: secret}
+
+	err := m.DB.QueryRowContext(ctx, stmt, userID, secret).Scan(&t.CreatedAt, &t.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrEditConflict
+		}
+
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func (m MFAModel) UpdateTOTP(t *TOTP) error {
+	stmt := `
+          UPDATE users_totp
+          SET enabled = $1, last_used_step = $2, version = version + 1
+          WHERE user_id = $3 AND version = $4
+          RETURNING version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{t.Enabled, t.LastUsedStep, t.UserID, t.Version}
+
+	err := m.DB.QueryRowContext(ctx, stmt, args...).Scan(&t.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// DeleteTOTP turns two-factor authentication off and drops the recovery codes.
+func (m MFAModel) DeleteTOTP(userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM users_totp WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// NewRecoveryCodes replaces the user's recovery codes and returns the new
+// plaintext codes. Only their hashes are stored.
+func (m MFAModel) NewRecoveryCodes(userID int64) ([]string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([][]byte, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		randomBytes := make([]byte, 5)
+
+		_, err := rand.Read(randomBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		code := strings.ToLower(encoding.EncodeToString(randomBytes))
+		hash := sha256.Sum256([]byte(code))
+
+		codes = append(codes, code[:4]+"-"+code[4:])
+		hashes = append(hashes, hash[:])
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hash := range hashes {
+		_, err = tx.ExecContext(ctx, `INSERT INTO recovery_codes (hash, user_id) VALUES ($1, $2)`, hash, userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// UseRecoveryCode consumes a recovery code, reporting whether it was valid.
+func (m MFAModel) UseRecoveryCode(userID int64, code string) (bool, error) {
+	stmt := `
+          DELETE FROM recovery_codes
+          WHERE user_id = $1 AND hash = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	hash := sha256.Sum256([]byte(normalizeRecoveryCode(code)))
+
+	result, err := m.DB.ExecContext(ctx, stmt, userID, hash[:])
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected == 1, nil
+}
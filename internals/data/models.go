@@ -9,12 +9,31 @@ var (
 	ErrNoRecordFound  = errors.New("record not found")
 	ErrEditConflict   = errors.New("edit conflict")
 	ErrDuplicateEmail = errors.New("duplicate email")
+	ErrTokenReused    = errors.New("token reused")
 )
 
 type Models struct {
-	Users    UsersModel
-	Tokens   TokensModel
-	Vehicles VehicleModel
+	Users          UsersModel
+	Tokens         TokensModel
+	Vehicles       VehicleModel
+	Permissions    PermissionModel
+	APIKeys        APIKeyModel
+	MFA            MFAModel
+	OIDC           OIDCModel
+	LoginThrottles LoginThrottleModel
+	Impersonations ImpersonationModel
+	Logins         LoginModel
+	Invitations    InvitationModel
+	Organizations  OrganizationModel
+	Followups      FollowupModel
+	CalendarFeeds  CalendarFeedModel
+	ServiceBays    ServiceBayModel
+	Appointments   AppointmentModel
+	Schedules      ScheduleModel
+	Documents      VehicleDocumentModel
+	Attachments    AttachmentModel
+	Comments       CommentModel
+	Calls          CallLogModel
 }
 
 func NewModels(db *sql.DB) Models {
@@ -22,5 +41,23 @@ func NewModels(db *sql.DB) Models {
 		UsersModel{DB: db},
 		TokensModel{DB: db},
 		VehicleModel{DB: db},
+		PermissionModel{DB: db},
+		APIKeyModel{DB: db},
+		MFAModel{DB: db},
+		OIDCModel{DB: db},
+		LoginThrottleModel{DB: db},
+		ImpersonationModel{DB: db},
+		LoginModel{DB: db},
+		InvitationModel{DB: db},
+		OrganizationModel{DB: db},
+		FollowupModel{DB: db},
+		CalendarFeedModel{DB: db},
+		ServiceBayModel{DB: db},
+		AppointmentModel{DB: db},
+		ScheduleModel{DB: db},
+		VehicleDocumentModel{DB: db},
+		AttachmentModel{DB: db},
+		CommentModel{DB: db},
+		CallLogModel{DB: db},
 	}
 }
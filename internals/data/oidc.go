@@ -0,0 +1,99 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// OIDCLoginState is what we remember between sending a user to the identity
+// provider and receiving the authorization code back.
+type OIDCLoginState struct {
+	Expiry       time.Time
+	State        string
+	Nonce        string
+	CodeVerifier string
+}
+
+type OIDCModel struct {
+	DB *sql.DB
+}
+
+func (m OIDCModel) InsertState(s *OIDCLoginState) error {
+	stmt := `
+          INSERT INTO oidc_login_states (state_hash, nonce, code_verifier, expiry)
+          VALUES ($1, $2, $3, $4)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	hash := sha256.Sum256([]byte(s.State))
+
+	_, err := m.DB.ExecContext(ctx, stmt, hash[:], s.Nonce, s.CodeVerifier, s.Expiry)
+	return err
+}
+
+// ConsumeState deletes and returns an unexpired login state, so each state
+// can complete at most one login.
+func (m OIDCModel) ConsumeState(state string) (*OIDCLoginState, error) {
+	stmt := `
+          DELETE FROM oidc_login_states
+          WHERE state_hash = $1 AND expiry > $2
+          RETURNING nonce, code_verifier, expiry`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	hash := sha256.Sum256([]byte(state))
+
+	s := OIDCLoginState{State: state}
+
+	err := m.DB.QueryRowContext(ctx, stmt, hash[:], time.Now()).Scan(&s.Nonce, &s.CodeVerifier, &s.Expiry)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordFound
+		}
+
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func (m OIDCModel) GetUserIDForIdentity(issuer, subject string) (int64, error) {
+	stmt := `
+          SELECT user_id
+          FROM user_identities
+          WHERE issuer = $1 AND subject = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var userID int64
+
+	err := m.DB.QueryRowContext(ctx, stmt, issuer, subject).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecordFound
+		}
+
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+func (m OIDCModel) LinkIdentity(issuer, subject string, userID int64) error {
+	stmt := `
+          INSERT INTO user_identities (issuer, subject, user_id)
+          VALUES ($1, $2, $3)
+          ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, issuer, subject, userID)
+	return err
+}
@@ -0,0 +1,86 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+	"github.com/lib/pq"
+)
+
+type Organization struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Timezone  string    `json:"timezone"`
+	CreatedAt time.Time `json:"created_at"`
+	Version   int       `json:"version"`
+}
+
+func ValidateOrganization(v *validator.Validator, org *Organization) {
+	v.Check(validator.NotBlank(org.Name), "name", "must be provided")
+	v.Check(len(org.Name) <= 500, "name", "must not be more than 500 bytes long")
+}
+
+type OrganizationModel struct {
+	DB *sql.DB
+}
+
+// Insert creates the organization with the user as its first admin, all in
+// one transaction. It returns ErrEditConflict if the user changed or joined
+// an organization in the meantime.
+func (m OrganizationModel) Insert(org *Organization, user *User) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `
+          INSERT INTO organizations (name)
+          VALUES ($1)
+          RETURNING id, timezone, created_at, version`
+
+	err = tx.QueryRowContext(ctx, stmt, org.Name).Scan(&org.ID, &org.Timezone, &org.CreatedAt, &org.Version)
+	if err != nil {
+		return err
+	}
+
+	stmt = `
+          UPDATE users
+          SET organization_id = $1, version = version + 1
+          WHERE id = $2 AND version = $3 AND organization_id IS NULL
+          RETURNING version`
+
+	err = tx.QueryRowContext(ctx, stmt, org.ID, user.ID, user.Version).Scan(&user.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+
+		return err
+	}
+
+	stmt = `
+          INSERT INTO users_permissions
+          SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)
+          ON CONFLICT DO NOTHING`
+
+	_, err = tx.ExecContext(ctx, stmt, user.ID, pq.Array(RolePermissions[RoleAdmin]))
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	user.OrganizationID = &org.ID
+
+	return nil
+}
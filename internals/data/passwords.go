@@ -0,0 +1,158 @@
+package data
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/passwordpolicy"
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	PasswordAlgorithmBcrypt   = "bcrypt"
+	PasswordAlgorithmArgon2id = "argon2id"
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var errInvalidPasswordHash = errors.New("invalid password hash")
+
+// PasswordParams selects how new password hashes are made. Hashes made with
+// other parameters keep working and are upgraded on the next login.
+type PasswordParams struct {
+	Algorithm         string
+	BcryptCost        int
+	Argon2Memory      uint32 // in KiB
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+}
+
+var DefaultPasswordParams = PasswordParams{
+	Algorithm:         PasswordAlgorithmArgon2id,
+	BcryptCost:        12,
+	Argon2Memory:      64 * 1024,
+	Argon2Iterations:  3,
+	Argon2Parallelism: 2,
+}
+
+// Validate reports parameters that can't be used to hash passwords.
+func (p PasswordParams) Validate() error {
+	switch p.Algorithm {
+	case PasswordAlgorithmBcrypt:
+		if p.BcryptCost < bcrypt.MinCost || p.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case PasswordAlgorithmArgon2id:
+		if p.Argon2Memory < 8*uint32(p.Argon2Parallelism) || p.Argon2Iterations < 1 || p.Argon2Parallelism < 1 {
+			return errors.New("argon2id needs at least 1 iteration, 1 thread and 8 KiB of memory per thread")
+		}
+	default:
+		return fmt.Errorf("unknown password algorithm %q", p.Algorithm)
+	}
+
+	return nil
+}
+
+// hashPassword returns a self-describing hash: bcrypt's own $2a$ format or
+// the PHC string format for argon2id.
+func hashPassword(plaintext string, p PasswordParams) ([]byte, error) {
+	if p.Algorithm == PasswordAlgorithmBcrypt {
+		return bcrypt.GenerateFromPassword([]byte(plaintext), p.BcryptCost)
+	}
+
+	salt := make([]byte, argon2SaltLength)
+
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	key := argon2.IDKey([]byte(plaintext), salt, p.Argon2Iterations, p.Argon2Memory, p.Argon2Parallelism, argon2KeyLength)
+
+	hash := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		p.Argon2Memory,
+		p.Argon2Iterations,
+		p.Argon2Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+
+	return []byte(hash), nil
+}
+
+type argon2Hash struct {
+	params PasswordParams
+	salt   []byte
+	key    []byte
+}
+
+func parseArgon2Hash(hash []byte) (*argon2Hash, error) {
+	parts := bytes.Split(hash, []byte("$"))
+	if len(parts) != 6 || string(parts[1]) != PasswordAlgorithmArgon2id {
+		return nil, errInvalidPasswordHash
+	}
+
+	var version int
+
+	_, err := fmt.Sscanf(string(parts[2]), "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return nil, errInvalidPasswordHash
+	}
+
+	h := argon2Hash{params: PasswordParams{Algorithm: PasswordAlgorithmArgon2id}}
+
+	_, err = fmt.Sscanf(string(parts[3]), "m=%d,t=%d,p=%d", &h.params.Argon2Memory, &h.params.Argon2Iterations, &h.params.Argon2Parallelism)
+	if err != nil {
+		return nil, errInvalidPasswordHash
+	}
+
+	h.salt, err = base64.RawStdEncoding.DecodeString(string(parts[4]))
+	if err != nil {
+		return nil, errInvalidPasswordHash
+	}
+
+	h.key, err = base64.RawStdEncoding.DecodeString(string(parts[5]))
+	if err != nil {
+		return nil, errInvalidPasswordHash
+	}
+
+	return &h, nil
+}
+
+func isArgon2Hash(hash []byte) bool {
+	return bytes.HasPrefix(hash, []byte("$"+PasswordAlgorithmArgon2id+"$"))
+}
+
+func (h *argon2Hash) matches(plaintext string) bool {
+	p := h.params
+	key := argon2.IDKey([]byte(plaintext), h.salt, p.Argon2Iterations, p.Argon2Memory, p.Argon2Parallelism, uint32(len(h.key)))
+
+	return subtle.ConstantTimeCompare(key, h.key) == 1
+}
+
+// maxPasswordLength is the longest password the algorithm of p can use in
+// full. bcrypt ignores everything after 72 bytes.
+func maxPasswordLength(p PasswordParams) int {
+	if p.Algorithm == PasswordAlgorithmBcrypt {
+		return 72
+	}
+	return 512
+}
+
+// ValidatePasswordPolicy checks a new password for strength, personal
+// information and presence on the policy's breached password list.
+func ValidatePasswordPolicy(v *validator.Validator, policy *passwordpolicy.Policy, plaintextPassword string, user *User) {
+	v.Check(policy.Score(plaintextPassword) >= policy.MinScore, "password", "is too weak, try a longer password or a passphrase")
+	v.Check(!policy.ContainsPersonalInfo(plaintextPassword, user.Email, user.Name), "password", "must not contain your name or email address")
+	v.Check(!policy.Breached(plaintextPassword), "password", "is too common or has appeared in a data breach")
+}
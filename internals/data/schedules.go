@@ -0,0 +1,416 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+)
+
+// maxScheduleSearch bounds the search for the next business moment, so that
+// a schedule without any open day can't loop forever.
+const maxScheduleSearch = 400
+
+// clockFormat is how business hours are written, e.g. "09:30".
+const clockFormat = "15:04"
+
+// BusinessHours is the time a workshop is open on a day of the week.
+type BusinessHours struct {
+	Weekday time.Weekday `json:"weekday"`
+	Opens   string       `json:"opens"`
+	Closes  string       `json:"closes"`
+}
+
+type Holiday struct {
+	ID             int64  `json:"id"`
+	OrganizationID int64  `json:"-"`
+	Date           string `json:"date"`
+	Name           string `json:"name"`
+}
+
+// DefaultBusinessHours apply to organizations that haven't set their own:
+// Monday to Saturday, 9 to 6.
+var DefaultBusinessHours = []BusinessHours{
+	{Weekday: time.Monday, Opens: "09:00", Closes: "18:00"},
+	{Weekday: time.Tuesday, Opens: "09:00", Closes: "18:00"},
+	{Weekday: time.Wednesday, Opens: "09:00", Closes: "18:00"},
+	{Weekday: time.Thursday, Opens: "09:00", Closes: "18:00"},
+	{Weekday: time.Friday, Opens: "09:00", Closes: "18:00"},
+	{Weekday: time.Saturday, Opens: "09:00", Closes: "18:00"},
+}
+
+func ValidateTimezone(v *validator.Validator, timezone string) {
+	_, err := time.LoadLocation(timezone)
+	v.Check(timezone != "" && err == nil, "timezone", "must be a valid IANA time zone such as Asia/Kolkata")
+}
+
+func ValidateBusinessHours(v *validator.Validator, hours []BusinessHours) {
+	seen := make(map[time.Weekday]bool)
+
+	for _, h := range hours {
+		v.Check(h.Weekday >= time.Sunday && h.Weekday <= time.Saturday, "business_hours", "weekday must be between 0 (Sunday) and 6 (Saturday)")
+		v.Check(!seen[h.Weekday], "business_hours", "must not contain the same weekday twice")
+		seen[h.Weekday] = true
+
+		opens, err1 := time.Parse(clockFormat, h.Opens)
+		closes, err2 := time.Parse(clockFormat, h.Closes)
+		v.Check(err1 == nil && err2 == nil, "business_hours", "opens and closes must be times such as 09:30")
+		v.Check(err1 != nil || err2 != nil || closes.After(opens), "business_hours", "must close after opening")
+	}
+}
+
+func ValidateHoliday(v *validator.Validator, h *Holiday) {
+	_, err := time.Parse(time.DateOnly, h.Date)
+	v.Check(err == nil, "date", "must be a date in the YYYY-MM-DD format")
+
+	v.Check(validator.NotBlank(h.Name), "name", "must be provided")
+	v.Check(validator.MaxChars(h.Name, 200), "name", "must not be more than 200 characters long")
+}
+
+// Schedule is an organization's working time: its business hours in its time
+// zone, minus holidays.
+type Schedule struct {
+	Location *time.Location
+	hours    map[time.Weekday][2]time.Duration
+	holidays map[string]bool
+}
+
+// NewSchedule builds a schedule. Holidays are dates in the YYYY-MM-DD format.
+func NewSchedule(loc *time.Location, hours []BusinessHours, holidays []string) (*Schedule, error) {
+	s := &Schedule{
+		Location: loc,
+		hours:    make(map[time.Weekday][2]time.Duration),
+		holidays: make(map[string]bool),
+	}
+
+	for _, h := range hours {
+		opens, err := clockOffset(h.Opens)
+		if err != nil {
+			return nil, err
+		}
+
+		closes, err := clockOffset(h.Closes)
+		if err != nil {
+			return nil, err
+		}
+
+		s.hours[h.Weekday] = [2]time.Duration{opens, closes}
+	}
+
+	for _, date := range holidays {
+		s.holidays[date] = true
+	}
+
+	return s, nil
+}
+
+// DefaultSchedule is the schedule of users outside any organization.
+func DefaultSchedule() *Schedule {
+	s, _ := NewSchedule(time.UTC, DefaultBusinessHours, nil)
+	return s
+}
+
+// Hours returns when the organization opens and closes on the day t falls on
+// in its time zone, or false if it's closed all day.
+func (s *Schedule) Hours(t time.Time) (time.Time, time.Time, bool) {
+	t = t.In(s.Location)
+
+	if s.holidays[t.Format(time.DateOnly)] {
+		return time.Time{}, time.Time{}, false
+	}
+
+	h, ok := s.hours[t.Weekday()]
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, s.Location)
+
+	return clockOn(midnight, h[0]), clockOn(midnight, h[1]), true
+}
+
+// NextBusinessMoment returns t if the organization is open then, and
+// otherwise the moment it next opens. If it never opens, t is returned as is.
+func (s *Schedule) NextBusinessMoment(t time.Time) time.Time {
+	day := t.In(s.Location)
+
+	for i := 0; i < maxScheduleSearch; i++ {
+		opens, closes, ok := s.Hours(day)
+
+		if ok && t.Before(closes) {
+			if t.Before(opens) {
+				return opens
+			}
+			return t
+		}
+
+		day = time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, s.Location)
+	}
+
+	return t
+}
+
+func clockOffset(clock string) (time.Duration, error) {
+	t, err := time.Parse(clockFormat, clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", clock)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// clockOn returns the wall clock time offset after midnight, so that hours
+// stay put across daylight saving changes.
+func clockOn(midnight time.Time, offset time.Duration) time.Time {
+	return time.Date(midnight.Year(), midnight.Month(), midnight.Day(), 0, int(offset/time.Minute), 0, 0, midnight.Location())
+}
+
+type ScheduleModel struct {
+	DB *sql.DB
+}
+
+func (m ScheduleModel) GetOrganization(id int64) (*Organization, error) {
+	stmt := `
+          SELECT id, name, timezone, created_at, version
+          FROM organizations
+          WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var org Organization
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&org.ID, &org.Name, &org.Timezone, &org.CreatedAt, &org.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordFound
+		}
+
+		return nil, err
+	}
+
+	return &org, nil
+}
+
+// GetBusinessHours returns the organization's hours, or DefaultBusinessHours
+// if it has never set any. An organization that set no hours at all is closed
+// every day and gets an empty list.
+func (m ScheduleModel) GetBusinessHours(organizationID int64) ([]BusinessHours, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var set bool
+
+	err := m.DB.QueryRowContext(ctx, `SELECT business_hours_set FROM organizations WHERE id = $1`, organizationID).Scan(&set)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordFound
+		}
+
+		return nil, err
+	}
+
+	if !set {
+		return DefaultBusinessHours, nil
+	}
+
+	stmt := `
+          SELECT weekday, to_char(opens, 'HH24:MI'), to_char(closes, 'HH24:MI')
+          FROM business_hours
+          WHERE organization_id = $1
+          ORDER BY weekday`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hours := make([]BusinessHours, 0, 7)
+
+	for rows.Next() {
+		var h BusinessHours
+
+		err := rows.Scan(&h.Weekday, &h.Opens, &h.Closes)
+		if err != nil {
+			return nil, err
+		}
+
+		hours = append(hours, h)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return hours, nil
+}
+
+// Update saves the organization's time zone and replaces its business hours.
+func (m ScheduleModel) Update(org *Organization, hours []BusinessHours) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `
+          UPDATE organizations
+          SET timezone = $1, business_hours_set = true, version = version + 1
+          WHERE id = $2 AND version = $3
+          RETURNING version`
+
+	err = tx.QueryRowContext(ctx, stmt, org.Timezone, org.ID, org.Version).Scan(&org.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM business_hours WHERE organization_id = $1`, org.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, h := range hours {
+		stmt := `
+              INSERT INTO business_hours (organization_id, weekday, opens, closes)
+              VALUES ($1, $2, $3, $4)`
+
+		_, err = tx.ExecContext(ctx, stmt, org.ID, h.Weekday, h.Opens, h.Closes)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetHolidays returns the organization's holidays on or after the date.
+func (m ScheduleModel) GetHolidays(organizationID int64, from time.Time) ([]*Holiday, error) {
+	stmt := `
+          SELECT id, organization_id, to_char(date, 'YYYY-MM-DD'), name
+          FROM holidays
+          WHERE organization_id = $1 AND date >= $2::date
+          ORDER BY date`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, organizationID, from.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holidays := make([]*Holiday, 0)
+
+	for rows.Next() {
+		var h Holiday
+
+		err := rows.Scan(&h.ID, &h.OrganizationID, &h.Date, &h.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		holidays = append(holidays, &h)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return holidays, nil
+}
+
+// InsertHolidays adds the holidays, renaming any that fall on a date the
+// organization already has a holiday on.
+func (m ScheduleModel) InsertHolidays(holidays []*Holiday) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `
+          INSERT INTO holidays (organization_id, date, name)
+          VALUES ($1, $2, $3)
+          ON CONFLICT (organization_id, date) DO UPDATE SET name = EXCLUDED.name
+          RETURNING id`
+
+	for _, h := range holidays {
+		err = tx.QueryRowContext(ctx, stmt, h.OrganizationID, h.Date, h.Name).Scan(&h.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m ScheduleModel) DeleteHoliday(id, organizationID int64) error {
+	stmt := `
+          DELETE FROM holidays
+          WHERE id = $1 AND organization_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, stmt, id, organizationID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoRecordFound
+	}
+
+	return nil
+}
+
+// GetSchedule loads the organization's schedule with its holidays from the
+// past day onwards.
+func (m ScheduleModel) GetSchedule(organizationID int64) (*Schedule, error) {
+	org, err := m.GetOrganization(organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := time.LoadLocation(org.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	hours, err := m.GetBusinessHours(organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	holidays, err := m.GetHolidays(organizationID, time.Now().AddDate(0, 0, -1))
+	if err != nil {
+		return nil, err
+	}
+
+	dates := make([]string, len(holidays))
+	for i, h := range holidays {
+		dates[i] = h.Date
+	}
+
+	return NewSchedule(loc, hours, dates)
+}
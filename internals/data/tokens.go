@@ -6,6 +6,7 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"encoding/base32"
+	"errors"
 	"time"
 
 	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
@@ -16,6 +17,9 @@ const (
 	ScopeAuthentication = "authentication"
 	ScopeRefresh        = "refresh"
 	ScopePasswordReset  = "password-reset"
+	ScopeMFA            = "mfa"
+	ScopeMagicLink      = "magic-link"
+	ScopeSessionRevoke  = "session-revoke"
 )
 
 type Token struct {
@@ -23,6 +27,7 @@ type Token struct {
 	Expiry    time.Time `json:"expiry"`
 	Scope     string    `json:"-"`
 	Hash      []byte    `json:"-"`
+	Family    []byte    `json:"-"`
 	UserID    int64     `json:"-"`
 }
 
@@ -48,6 +53,19 @@ func generateToken(userID int64, ttl time.Duration, scope string) (*Token, error
 	return &token, nil
 }
 
+// NewTokenFamily returns a random identifier shared by every token issued
+// from a single login, so that a compromised session can be revoked as a whole.
+func NewTokenFamily() ([]byte, error) {
+	family := make([]byte, 16)
+
+	_, err := rand.Read(family)
+	if err != nil {
+		return nil, err
+	}
+
+	return family, nil
+}
+
 func ValidatePlaintextToken(v *validator.Validator, plaintextToken string) {
 	v.Check(plaintextToken != "", "token", "must be provided")
 	v.Check(len(plaintextToken) == 26, "token", "must be 26 bytes long")
@@ -67,16 +85,28 @@ func (m TokensModel) New(userID int64, ttl time.Duration, scope string) (*Token,
 	return token, err
 }
 
+func (m TokensModel) NewInFamily(userID int64, ttl time.Duration, scope string, family []byte) (*Token, error) {
+	token, err := generateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	token.Family = family
+
+	err = m.Insert(token)
+	return token, err
+}
+
 func (m TokensModel) Insert(token *Token) error {
 	stmt := `
-          INSERT INTO tokens (hash, user_id, expiry, scope)
-          VALUES ($1, $2, $3, $4)
+          INSERT INTO tokens (hash, user_id, expiry, scope, family)
+          VALUES ($1, $2, $3, $4, $5)
           `
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	args := []any{token.Hash, token.UserID, token.Expiry, token.Scope}
+	args := []any{token.Hash, token.UserID, token.Expiry, token.Scope, token.Family}
 	_, err := m.DB.ExecContext(ctx, stmt, args...)
 
 	return err
@@ -111,3 +141,191 @@ func (m TokensModel) ClearAllForUser(userID int64) error {
 
 	return err
 }
+
+// ConsumeRefresh marks an unexpired refresh token as used and returns it. If
+// the token was already used ErrTokenReused is returned along with the token,
+// so the caller can revoke its family. Unless userID is 0, tokens of other
+// users are not found and left untouched.
+func (m TokensModel) ConsumeRefresh(tokenPlaintext string, userID int64) (*Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt := `
+          SELECT hash, user_id, expiry, scope, family, used
+          FROM tokens
+          WHERE hash = $1 AND scope = $2
+          FOR UPDATE`
+
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	var token Token
+	var used bool
+
+	err = tx.QueryRowContext(ctx, stmt, tokenHash[:], ScopeRefresh).Scan(
+		&token.Hash,
+		&token.UserID,
+		&token.Expiry,
+		&token.Scope,
+		&token.Family,
+		&used,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordFound
+		}
+
+		return nil, err
+	}
+
+	if userID != 0 && token.UserID != userID {
+		return nil, ErrNoRecordFound
+	}
+
+	if used {
+		return &token, ErrTokenReused
+	}
+
+	if !token.Expiry.After(time.Now()) {
+		return nil, ErrNoRecordFound
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE tokens SET used = true WHERE hash = $1`, token.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// Consume deletes an unexpired token and returns the ID of its user. Only one
+// of several concurrent callers with the same token gets the user back; the
+// others get ErrNoRecordFound.
+func (m TokensModel) Consume(tokenPlaintext, scope string) (int64, error) {
+	stmt := `
+          DELETE FROM tokens
+          WHERE hash = $1 AND scope = $2 AND expiry > $3
+          RETURNING user_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	var userID int64
+	err := m.DB.QueryRowContext(ctx, stmt, tokenHash[:], scope, time.Now()).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecordFound
+		}
+
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+func (m TokensModel) DeleteFamily(family []byte) error {
+	stmt := `
+          DELETE FROM tokens
+          WHERE family=$1
+          `
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, stmt, family)
+
+	return err
+}
+
+// LatestExpiry returns the expiry of the user's most recently issued token in
+// the scope, which tells callers when that token was issued.
+func (m TokensModel) LatestExpiry(userID int64, scope string) (time.Time, error) {
+	stmt := `
+          SELECT MAX(expiry)
+          FROM tokens
+          WHERE user_id=$1 AND scope=$2
+          `
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var expiry sql.NullTime
+
+	err := m.DB.QueryRowContext(ctx, stmt, userID, scope).Scan(&expiry)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !expiry.Valid {
+		return time.Time{}, ErrNoRecordFound
+	}
+
+	return expiry.Time, nil
+}
+
+// DeleteExpired removes at most limit expired tokens and returns how many
+// were deleted. Callers repeat it until it deletes less than limit, keeping
+// each statement short. Cancelling ctx aborts the statement.
+func (m TokensModel) DeleteExpired(ctx context.Context, limit int) (int64, error) {
+	stmt := `
+          DELETE FROM tokens
+          WHERE hash IN (
+              SELECT hash FROM tokens
+              WHERE expiry < $1
+              LIMIT $2
+          )`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, stmt, time.Now(), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (m TokensModel) Get(tokenPlaintext, scope string) (*Token, error) {
+	stmt := `
+          SELECT hash, user_id, expiry, scope, family
+          FROM tokens
+          WHERE hash = $1 AND scope = $2 AND expiry > $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	var token Token
+	err := m.DB.QueryRowContext(ctx, stmt, tokenHash[:], scope, time.Now()).Scan(
+		&token.Hash,
+		&token.UserID,
+		&token.Expiry,
+		&token.Scope,
+		&token.Family,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordFound
+		}
+
+		return nil, err
+	}
+
+	token.Plaintext = tokenPlaintext
+
+	return &token, nil
+}
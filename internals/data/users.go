@@ -5,21 +5,32 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
+	"github.com/PriyanshuSharma23/follow-ups-server/internals/passwordpolicy"
 	"github.com/PriyanshuSharma23/follow-ups-server/internals/validator"
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type User struct {
-	CreatedAt time.Time `json:"created_at"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Password  password  `json:"-"`
-	ID        int64     `json:"id"`
-	Version   int       `json:"-"`
-	Activated bool      `json:"activated"`
+	CreatedAt      time.Time  `json:"created_at"`
+	Name           string     `json:"name"`
+	Email          string     `json:"email"`
+	Password       password   `json:"-"`
+	ID             int64      `json:"id"`
+	OrganizationID *int64     `json:"organization_id,omitempty"`
+	DeactivatedAt  *time.Time `json:"deactivated_at,omitempty"`
+	Version        int        `json:"-"`
+	Activated      bool       `json:"activated"`
+}
+
+// IsDeactivated reports whether an admin has deactivated the user. Unlike an
+// account that was never activated, it can only be undone by an admin.
+func (u *User) IsDeactivated() bool {
+	return u.DeactivatedAt != nil
 }
 
 var AnonymousUser = new(User)
@@ -31,21 +42,34 @@ func (u *User) IsAnonymous() bool {
 type password struct {
 	plaintext *string
 	hash      []byte
+	params    PasswordParams
 }
 
-func (p *password) Set(plaintextPassword string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(plaintextPassword), 12)
+// Set hashes the password with params, which are kept to validate the
+// plaintext against.
+func (p *password) Set(plaintextPassword string, params PasswordParams) error {
+	hash, err := hashPassword(plaintextPassword, params)
 	if err != nil {
 		return err
 	}
 
 	p.hash = hash
 	p.plaintext = &plaintextPassword
+	p.params = params
 
 	return nil
 }
 
 func (p *password) Matches(plaintextPassword string) (bool, error) {
+	if isArgon2Hash(p.hash) {
+		h, err := parseArgon2Hash(p.hash)
+		if err != nil {
+			return false, err
+		}
+
+		return h.matches(plaintextPassword), nil
+	}
+
 	err := bcrypt.CompareHashAndPassword(p.hash, []byte(plaintextPassword))
 	if err != nil {
 		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
@@ -58,10 +82,33 @@ func (p *password) Matches(plaintextPassword string) (bool, error) {
 	return true, nil
 }
 
-func ValidatePasswordPlaintext(v *validator.Validator, plaintextPassword string) {
+// NeedsRehash reports whether the hash was made with an algorithm or
+// parameters other than params.
+func (p *password) NeedsRehash(params PasswordParams) bool {
+	if isArgon2Hash(p.hash) {
+		h, err := parseArgon2Hash(p.hash)
+		if err != nil {
+			return true
+		}
+
+		return params.Algorithm != PasswordAlgorithmArgon2id ||
+			h.params.Argon2Memory != params.Argon2Memory ||
+			h.params.Argon2Iterations != params.Argon2Iterations ||
+			h.params.Argon2Parallelism != params.Argon2Parallelism
+	}
+
+	cost, err := bcrypt.Cost(p.hash)
+	if err != nil {
+		return true
+	}
+
+	return params.Algorithm != PasswordAlgorithmBcrypt || cost != params.BcryptCost
+}
+
+func ValidatePasswordPlaintext(v *validator.Validator, plaintextPassword string, params PasswordParams) {
 	v.Check(validator.NotBlank(plaintextPassword), "password", "must be provided")
 	v.Check(len(plaintextPassword) >= 8, "password", "must be atleast 8 bytes long.")
-	v.Check(len(plaintextPassword) <= 72, "password", "must be atmost 72 bytes long.")
+	v.Check(len(plaintextPassword) <= maxPasswordLength(params), "password", fmt.Sprintf("must be atmost %d bytes long.", maxPasswordLength(params)))
 }
 
 func ValidateEmail(v *validator.Validator, email string) {
@@ -69,14 +116,16 @@ func ValidateEmail(v *validator.Validator, email string) {
 	v.Check(validator.Matches(email, validator.EmailRx), "email", "must be a valid email address")
 }
 
-func ValidateUser(v *validator.Validator, user *User) {
+// ValidateUser checks the user, holding a new password to the policy.
+func ValidateUser(v *validator.Validator, user *User, policy *passwordpolicy.Policy) {
 	v.Check(user.Name != "", "name", "must be provided")
 	v.Check(len(user.Name) <= 500, "name", "must not be more than 500 bytes long")
 
 	ValidateEmail(v, user.Email)
 
 	if user.Password.plaintext != nil {
-		ValidatePasswordPlaintext(v, *user.Password.plaintext)
+		ValidatePasswordPlaintext(v, *user.Password.plaintext, user.Password.params)
+		ValidatePasswordPolicy(v, policy, *user.Password.plaintext, user)
 	}
 
 	// Remeber to Set password before calling this function
@@ -90,14 +139,14 @@ type UsersModel struct {
 }
 
 func (m UsersModel) Insert(user *User) error {
-	stmt := `INSERT INTO users (name, email, password_hash, activated)
-		     VALUES ($1, $2, $3, $4) 
+	stmt := `INSERT INTO users (name, email, password_hash, activated, organization_id)
+		     VALUES ($1, $2, $3, $4, $5) 
 			 RETURNING id, created_at, version`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	args := []any{user.Name, user.Email, user.Password.hash, user.Activated}
+	args := []any{user.Name, user.Email, user.Password.hash, user.Activated, user.OrganizationID}
 	err := m.DB.QueryRowContext(ctx, stmt, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
 	if err != nil {
 		switch {
@@ -111,8 +160,40 @@ func (m UsersModel) Insert(user *User) error {
 	return nil
 }
 
+func (m UsersModel) Get(id int64) (*User, error) {
+	stmt := `SELECT id, created_at, name, email, password_hash, activated, organization_id, deactivated_at, version 
+	 		 FROM users
+			 WHERE id=$1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var user User
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.OrganizationID,
+		&user.DeactivatedAt,
+		&user.Version,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordFound
+		}
+
+		return nil, err
+	}
+
+	return &user, nil
+}
+
 func (m UsersModel) GetByEmail(email string) (*User, error) {
-	stmt := `SELECT id, created_at, name, email, password_hash, activated, version 
+	stmt := `SELECT id, created_at, name, email, password_hash, activated, organization_id, deactivated_at, version 
 	 		 FROM users
 			 WHERE email=$1`
 
@@ -128,6 +209,8 @@ func (m UsersModel) GetByEmail(email string) (*User, error) {
 		&user.Email,
 		&user.Password.hash,
 		&user.Activated,
+		&user.OrganizationID,
+		&user.DeactivatedAt,
 		&user.Version,
 	)
 	if err != nil {
@@ -143,7 +226,7 @@ func (m UsersModel) GetByEmail(email string) (*User, error) {
 
 func (m UsersModel) GetForToken(tokenPlaintext, scope string) (*User, error) {
 	stmt := `
-          SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version 
+          SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.organization_id, users.deactivated_at, users.version 
           FROM users
           INNER JOIN tokens
           ON users.id = tokens.user_id
@@ -162,6 +245,8 @@ func (m UsersModel) GetForToken(tokenPlaintext, scope string) (*User, error) {
 		&user.Email,
 		&user.Password.hash,
 		&user.Activated,
+		&user.OrganizationID,
+		&user.DeactivatedAt,
 		&user.Version,
 	)
 	if err != nil {
@@ -175,12 +260,14 @@ func (m UsersModel) GetForToken(tokenPlaintext, scope string) (*User, error) {
 	return &user, nil
 }
 
+// UpdateUser saves the user. It never activates a deactivated user, see
+// SetDeactivated.
 func (m UsersModel) UpdateUser(user *User) error {
 	stmt := `
 			UPDATE users 
-			SET name=$1, email=$2, password_hash=$3, activated=$4, version=version + 1
-			WHERE id=$5 AND version=$6
-			RETURNING version
+			SET name=$1, email=$2, password_hash=$3, activated=$4 AND deactivated_at IS NULL, organization_id=$5, version=version + 1
+			WHERE id=$6 AND version=$7
+			RETURNING activated, version
 			`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -190,13 +277,16 @@ func (m UsersModel) UpdateUser(user *User) error {
 		user.Email,
 		user.Password.hash,
 		user.Activated,
+		user.OrganizationID,
 		user.ID,
 		user.Version,
 	}
 
-	err := m.DB.QueryRowContext(ctx, stmt, args...).Scan(&user.Version)
+	err := m.DB.QueryRowContext(ctx, stmt, args...).Scan(&user.Activated, &user.Version)
 	if err != nil {
 		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
 		case strings.Contains(err.Error(), "users_email_key"):
 			return ErrDuplicateEmail
 		default:
@@ -206,3 +296,152 @@ func (m UsersModel) UpdateUser(user *User) error {
 
 	return nil
 }
+
+// SetDeactivated deactivates the user, or reactivates them. Deactivation also
+// clears activated, and reactivation activates the account, so that a user an
+// admin has vouched for doesn't need to go through activation again. This is
+// the only place deactivated_at is written.
+func (m UsersModel) SetDeactivated(user *User, deactivated bool) error {
+	stmt := `
+			UPDATE users
+			SET deactivated_at = CASE WHEN $1 THEN NOW() END, activated = NOT $1, version = version + 1
+			WHERE id = $2 AND version = $3
+			RETURNING deactivated_at, activated, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, stmt, deactivated, user.ID, user.Version).Scan(&user.DeactivatedAt, &user.Activated, &user.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetAllInOrganization returns the organization's users with the given email
+// addresses. Addresses of users outside the organization are skipped.
+func (m UsersModel) GetAllInOrganization(organizationID int64, emails []string) ([]*User, error) {
+	stmt := `SELECT id, created_at, name, email, password_hash, activated, organization_id, version
+           FROM users
+           WHERE organization_id = $1 AND email = ANY($2::citext[])
+           ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, organizationID, pq.Array(emails))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]*User, 0)
+
+	for rows.Next() {
+		var user User
+
+		err := rows.Scan(
+			&user.ID,
+			&user.CreatedAt,
+			&user.Name,
+			&user.Email,
+			&user.Password.hash,
+			&user.Activated,
+			&user.OrganizationID,
+			&user.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, &user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// UserFilters narrows down GetAll. Zero values match every user;
+// OrganizationID, when set, keeps the members of that organization.
+type UserFilters struct {
+	OrganizationID *int64
+	Email          string
+	Activated      *bool
+	CreatedAfter   *time.Time
+	CreatedBefore  *time.Time
+	Filters
+}
+
+func (m UsersModel) GetAll(f UserFilters) ([]*User, Metadata, error) {
+	stmt := fmt.Sprintf(`SELECT COUNT(*) OVER(), id, created_at, name, email, password_hash, activated, organization_id, deactivated_at, version
+           FROM users
+           WHERE (strpos(lower(email), lower($1)) > 0 OR $1 = '')
+           AND ($2::boolean IS NULL OR activated = $2)
+           AND ($3::timestamptz IS NULL OR created_at >= $3)
+           AND ($4::timestamptz IS NULL OR created_at < $4)
+           AND ($5::bigint IS NULL OR organization_id = $5)
+		   ORDER BY %s %s, id ASC
+		   LIMIT $6 OFFSET $7`, f.sortColumn(), f.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{
+		f.Email,
+		f.Activated,
+		f.CreatedAfter,
+		f.CreatedBefore,
+		f.OrganizationID,
+		f.limit(),
+		f.offset(),
+	}
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var totalRecords int
+
+	users := make([]*User, 0)
+
+	for rows.Next() {
+		var user User
+
+		err := rows.Scan(
+			&totalRecords,
+			&user.ID,
+			&user.CreatedAt,
+			&user.Name,
+			&user.Email,
+			&user.Password.hash,
+			&user.Activated,
+			&user.OrganizationID,
+			&user.DeactivatedAt,
+			&user.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		users = append(users, &user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, f.Page, f.PageSize)
+
+	return users, metadata, nil
+}
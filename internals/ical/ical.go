@@ -0,0 +1,207 @@
+// Package ical writes RFC 5545 iCalendar feeds.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	StatusTentative = "TENTATIVE"
+	StatusConfirmed = "CONFIRMED"
+	StatusCancelled = "CANCELLED"
+)
+
+const (
+	dateTimeFormat = "20060102T150405Z"
+
+	// maxLineLength is the longest a content line may be, in octets, before it
+	// has to be folded.
+	maxLineLength = 75
+)
+
+type Calendar struct {
+	ProdID string
+	Name   string
+	Events []Event
+}
+
+type Event struct {
+	Start       time.Time
+	Stamp       time.Time
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Status      string
+	Alarms      []Alarm
+	Duration    time.Duration
+	Sequence    int
+	AllDay      bool
+}
+
+// Alarm shows a reminder Before the start of its event.
+type Alarm struct {
+	Description string
+	Before      time.Duration
+}
+
+// WriteTo writes the calendar as a text/calendar document.
+func (c *Calendar) WriteTo(w io.Writer) (int64, error) {
+	cw := &writer{w: bufio.NewWriter(w)}
+
+	cw.line("BEGIN", "VCALENDAR")
+	cw.line("VERSION", "2.0")
+	cw.line("PRODID", c.ProdID)
+	cw.line("CALSCALE", "GREGORIAN")
+	cw.line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		cw.line("X-WR-CALNAME", escape(c.Name))
+	}
+
+	for i := range c.Events {
+		c.Events[i].write(cw)
+	}
+
+	cw.line("END", "VCALENDAR")
+
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+
+	return cw.n, cw.err
+}
+
+func (e *Event) write(cw *writer) {
+	cw.line("BEGIN", "VEVENT")
+	cw.line("UID", e.UID)
+	cw.line("SEQUENCE", fmt.Sprint(e.Sequence))
+	cw.line("DTSTAMP", e.Stamp.UTC().Format(dateTimeFormat))
+	if e.AllDay {
+		cw.line("DTSTART;VALUE=DATE", e.Start.Format(dateFormat))
+	} else {
+		cw.line("DTSTART", e.Start.UTC().Format(dateTimeFormat))
+	}
+	cw.line("DURATION", duration(e.Duration))
+	cw.line("SUMMARY", escape(e.Summary))
+	if e.Description != "" {
+		cw.line("DESCRIPTION", escape(e.Description))
+	}
+	if e.Location != "" {
+		cw.line("LOCATION", escape(e.Location))
+	}
+	if e.Status != "" {
+		cw.line("STATUS", e.Status)
+	}
+
+	for _, a := range e.Alarms {
+		cw.line("BEGIN", "VALARM")
+		cw.line("ACTION", "DISPLAY")
+		cw.line("TRIGGER", "-"+duration(a.Before))
+		cw.line("DESCRIPTION", escape(a.Description))
+		cw.line("END", "VALARM")
+	}
+
+	cw.line("END", "VEVENT")
+}
+
+// writer folds content lines and keeps the first error, so that callers can
+// write a whole calendar and check once.
+type writer struct {
+	w   *bufio.Writer
+	err error
+	n   int64
+}
+
+func (cw *writer) line(name, value string) {
+	if cw.err != nil {
+		return
+	}
+
+	s := fold(name + ":" + value)
+
+	n, err := cw.w.WriteString(s + "\r\n")
+	cw.n += int64(n)
+	cw.err = err
+}
+
+// fold splits a content line into lines of at most maxLineLength octets,
+// continuing each with a single space, without breaking UTF-8 sequences.
+func fold(s string) string {
+	if len(s) <= maxLineLength {
+		return s
+	}
+
+	var b strings.Builder
+
+	limit := maxLineLength
+	length := 0
+
+	for _, r := range s {
+		size := utf8.RuneLen(r)
+
+		if length+size > limit {
+			b.WriteString("\r\n ")
+			length = 0
+			limit = maxLineLength - 1
+		}
+
+		b.WriteRune(r)
+		length += size
+	}
+
+	return b.String()
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// duration formats d as an RFC 5545 duration such as PT1H30M.
+func duration(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+
+	d = d.Round(time.Second)
+
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+
+	hours := d / time.Hour
+	d -= hours * time.Hour
+
+	minutes := d / time.Minute
+	d -= minutes * time.Minute
+
+	seconds := d / time.Second
+
+	var b strings.Builder
+	b.WriteString("P")
+
+	if days > 0 {
+		fmt.Fprintf(&b, "%dD", days)
+	}
+
+	if hours > 0 || minutes > 0 || seconds > 0 || days == 0 {
+		b.WriteString("T")
+
+		if hours > 0 {
+			fmt.Fprintf(&b, "%dH", hours)
+		}
+		if minutes > 0 {
+			fmt.Fprintf(&b, "%dM", minutes)
+		}
+		if seconds > 0 || (hours == 0 && minutes == 0) {
+			fmt.Fprintf(&b, "%dS", seconds)
+		}
+	}
+
+	return b.String()
+}
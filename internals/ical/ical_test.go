@@ -0,0 +1,271 @@
+package ical
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"short", "SUMMARY:oil change"},
+		{"exactly the limit", "SUMMARY:" + strings.Repeat("a", maxLineLength-len("SUMMARY:"))},
+		{"long", "DESCRIPTION:" + strings.Repeat("abcdefghij", 20)},
+		{"multibyte", "SUMMARY:" + strings.Repeat("é€", 40)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folded := fold(tt.in)
+
+			for i, line := range strings.Split(folded, "\r\n") {
+				if len(line) > maxLineLength {
+					t.Errorf("line %d is %d octets long", i, len(line))
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("line %d splits a UTF-8 sequence: %q", i, line)
+				}
+				if i > 0 && !strings.HasPrefix(line, " ") {
+					t.Errorf("continuation line %d doesn't start with a space", i)
+				}
+			}
+
+			lines, err := unfold(strings.NewReader(folded))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(lines) != 1 || lines[0] != tt.in {
+				t.Errorf("unfold(fold(s)) = %q, want %q", lines, tt.in)
+			}
+		})
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{`a\b`, `a\\b`},
+		{"a;b,c", `a\;b\,c`},
+		{"line\nbreak", `line\nbreak`},
+		{"line\r\nbreak", `line\nbreak`},
+	}
+
+	for _, tt := range tests {
+		got := escape(tt.in)
+		if got != tt.want {
+			t.Errorf("escape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+
+		want := strings.ReplaceAll(tt.in, "\r\n", "\n")
+		if back := unescape(got); back != want {
+			t.Errorf("unescape(%q) = %q, want %q", got, back, want)
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{0, "PT0S"},
+		{30 * time.Second, "PT30S"},
+		{15 * time.Minute, "PT15M"},
+		{90 * time.Minute, "PT1H30M"},
+		{24 * time.Hour, "P1D"},
+		{26*time.Hour + 5*time.Second, "P1DT2H5S"},
+		{-time.Hour, "PT1H"},
+	}
+
+	for _, tt := range tests {
+		got := duration(tt.in)
+		if got != tt.want {
+			t.Errorf("duration(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+
+		back, err := parseDuration(got)
+		if err != nil {
+			t.Errorf("parseDuration(%q): %v", got, err)
+			continue
+		}
+		if want := tt.in.Abs(); back != want {
+			t.Errorf("parseDuration(%q) = %v, want %v", got, back, want)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"P2W", 14 * 24 * time.Hour, false},
+		{"+PT45M", 45 * time.Minute, false},
+		{"P1DT12H", 36 * time.Hour, false},
+		{"P", 0, true},
+		{"PT", 0, true},
+		{"1H", 0, true},
+		{"PT5", 0, true},
+		{"PTH", 0, true},
+		{"-PT5M", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseDuration(tt.in)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidCalendar) {
+				t.Errorf("parseDuration(%q) error = %v, want ErrInvalidCalendar", tt.in, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseDuration(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	doc := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"UID:timed@example.com",
+		"DTSTART;TZID=Asia/Kolkata:20240501T093000",
+		"DTEND;TZID=Asia/Kolkata:20240501T110000",
+		"SUMMARY:Service\\, wash",
+		"DESCRIPTION:first line\\nsecond ",
+		" line",
+		"STATUS:CONFIRMED",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:allday@example.com",
+		"DTSTART;VALUE=DATE:20240502",
+		"SUMMARY:Holiday",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:utc@example.com",
+		"DTSTART:20240503T080000Z",
+		"DURATION:PT45M",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	events, err := Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+
+	want := []Event{
+		{
+			UID:         "timed@example.com",
+			Start:       time.Date(2024, time.May, 1, 9, 30, 0, 0, kolkata),
+			Duration:    90 * time.Minute,
+			Summary:     "Service, wash",
+			Description: "first line\nsecond line",
+			Status:      StatusConfirmed,
+		},
+		{
+			UID:      "allday@example.com",
+			Start:    time.Date(2024, time.May, 2, 0, 0, 0, 0, time.UTC),
+			Duration: 24 * time.Hour,
+			Summary:  "Holiday",
+			AllDay:   true,
+		},
+		{
+			UID:      "utc@example.com",
+			Start:    time.Date(2024, time.May, 3, 8, 0, 0, 0, time.UTC),
+			Duration: 45 * time.Minute,
+		},
+	}
+
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(events), len(want))
+	}
+
+	for i := range want {
+		got, w := events[i], want[i]
+		if got.UID != w.UID || !got.Start.Equal(w.Start) || got.Duration != w.Duration ||
+			got.Summary != w.Summary || got.Description != w.Description ||
+			got.Status != w.Status || got.AllDay != w.AllDay {
+			t.Errorf("event %d = %+v, want %+v", i, got, w)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := map[string]string{
+		"no DTSTART":     "BEGIN:VEVENT\r\nUID:x\r\nEND:VEVENT",
+		"unterminated":   "BEGIN:VEVENT\r\nDTSTART:20240501T090000Z",
+		"malformed line": "BEGIN:VEVENT\r\nSUMMARY\r\nEND:VEVENT",
+		"bad date":       "BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:2024050x\r\nEND:VEVENT",
+		"bad time zone":  "BEGIN:VEVENT\r\nDTSTART;TZID=Nowhere/Atlantis:20240501T090000\r\nEND:VEVENT",
+		"bad duration":   "BEGIN:VEVENT\r\nDTSTART:20240501T090000Z\r\nDURATION:1H\r\nEND:VEVENT",
+	}
+
+	for name, doc := range tests {
+		_, err := Parse(strings.NewReader(doc))
+		if !errors.Is(err, ErrInvalidCalendar) {
+			t.Errorf("%s: error = %v, want ErrInvalidCalendar", name, err)
+		}
+	}
+}
+
+func TestWriteToRoundTrip(t *testing.T) {
+	start := time.Date(2024, time.June, 10, 4, 30, 0, 0, time.UTC)
+
+	cal := &Calendar{
+		ProdID: "-//follow-ups//EN",
+		Name:   "Follow-ups",
+		Events: []Event{
+			{
+				UID:         "followup-1@follow-ups",
+				Start:       start,
+				Stamp:       start,
+				Duration:    30 * time.Minute,
+				Summary:     "Call back; ask about tyres, brakes",
+				Description: strings.Repeat("a long note that needs folding ", 5),
+				Status:      StatusTentative,
+				Alarms:      []Alarm{{Description: "Call back", Before: 15 * time.Minute}},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+
+	n, err := cal.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo returned %d, wrote %d", n, buf.Len())
+	}
+
+	events, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+
+	got, want := events[0], cal.Events[0]
+	if got.UID != want.UID || !got.Start.Equal(want.Start) || got.Duration != want.Duration ||
+		got.Summary != want.Summary || got.Description != want.Description || got.Status != want.Status {
+		t.Errorf("round trip = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,219 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const dateFormat = "20060102"
+
+var ErrInvalidCalendar = errors.New("invalid calendar")
+
+// Parse reads the events of an iCalendar document. Only the properties this
+// package writes are kept, and recurring events are not expanded. All-day
+// events start at midnight UTC and last whole days.
+func Parse(r io.Reader) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	var event *Event
+	var end time.Time
+
+	// nested counts the components open inside the current event, such as
+	// alarms, whose properties must not be taken for the event's.
+	nested := 0
+
+	for _, line := range lines {
+		name, params, value, err := splitLine(line)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			event = &Event{}
+			end = time.Time{}
+			nested = 0
+		case event != nil && name == "BEGIN":
+			nested++
+		case event != nil && name == "END" && nested > 0:
+			nested--
+		case nested > 0:
+		case name == "END" && value == "VEVENT":
+			if event == nil || event.Start.IsZero() {
+				return nil, fmt.Errorf("%w: event without DTSTART", ErrInvalidCalendar)
+			}
+
+			switch {
+			case !end.IsZero():
+				event.Duration = end.Sub(event.Start)
+			case event.AllDay:
+				event.Duration = 24 * time.Hour
+			}
+
+			events = append(events, *event)
+			event = nil
+		case event == nil:
+		case name == "UID":
+			event.UID = value
+		case name == "SUMMARY":
+			event.Summary = unescape(value)
+		case name == "DESCRIPTION":
+			event.Description = unescape(value)
+		case name == "LOCATION":
+			event.Location = unescape(value)
+		case name == "STATUS":
+			event.Status = value
+		case name == "DTSTART":
+			event.Start, err = parseTime(params, value)
+			if err != nil {
+				return nil, err
+			}
+			event.AllDay = len(value) == len(dateFormat)
+		case name == "DURATION":
+			event.Duration, err = parseDuration(value)
+			if err != nil {
+				return nil, err
+			}
+		case name == "DTEND":
+			end, err = parseTime(params, value)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if event != nil {
+		return nil, fmt.Errorf("%w: unterminated event", ErrInvalidCalendar)
+	}
+
+	return events, nil
+}
+
+// unfold joins continuation lines, which start with a space or tab, to the
+// line before them.
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+
+	var lines []string
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, scanner.Err()
+}
+
+func splitLine(line string) (name, params, value string, err error) {
+	head, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", "", "", fmt.Errorf("%w: malformed line %q", ErrInvalidCalendar, line)
+	}
+
+	name, params, _ = strings.Cut(head, ";")
+
+	return strings.ToUpper(name), params, value, nil
+}
+
+// parseTime reads a DATE, a UTC DATE-TIME or a DATE-TIME with a TZID
+// parameter. Floating times are taken as UTC.
+func parseTime(params, value string) (time.Time, error) {
+	if len(value) == len(dateFormat) {
+		t, err := time.Parse(dateFormat, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: bad date %q", ErrInvalidCalendar, value)
+		}
+		return t, nil
+	}
+
+	loc := time.UTC
+
+	for _, param := range strings.Split(params, ";") {
+		key, tzid, _ := strings.Cut(param, "=")
+		if strings.EqualFold(key, "TZID") {
+			l, err := time.LoadLocation(strings.Trim(tzid, `"`))
+			if err != nil {
+				return time.Time{}, fmt.Errorf("%w: unknown time zone %q", ErrInvalidCalendar, tzid)
+			}
+			loc = l
+		}
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		loc = time.UTC
+	}
+
+	t, err := time.ParseInLocation("20060102T150405", strings.TrimSuffix(value, "Z"), loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: bad date-time %q", ErrInvalidCalendar, value)
+	}
+
+	return t, nil
+}
+
+// parseDuration reads a positive RFC 5545 duration such as P1D or PT1H30M.
+func parseDuration(value string) (time.Duration, error) {
+	rest, ok := strings.CutPrefix(strings.TrimPrefix(value, "+"), "P")
+	if !ok || rest == "" {
+		return 0, fmt.Errorf("%w: bad duration %q", ErrInvalidCalendar, value)
+	}
+
+	units := map[byte]time.Duration{
+		'W': 7 * 24 * time.Hour,
+		'D': 24 * time.Hour,
+		'H': time.Hour,
+		'M': time.Minute,
+		'S': time.Second,
+	}
+
+	var d time.Duration
+	n := 0
+	digits := false
+	elements := 0
+
+	for i := 0; i < len(rest); i++ {
+		c := rest[i]
+
+		switch {
+		case c >= '0' && c <= '9':
+			n = n*10 + int(c-'0')
+			digits = true
+		case c == 'T':
+		case units[c] != 0 && digits:
+			d += time.Duration(n) * units[c]
+			n = 0
+			digits = false
+			elements++
+		default:
+			return 0, fmt.Errorf("%w: bad duration %q", ErrInvalidCalendar, value)
+		}
+	}
+
+	if digits || elements == 0 {
+		return 0, fmt.Errorf("%w: bad duration %q", ErrInvalidCalendar, value)
+	}
+
+	return d, nil
+}
+
+var unescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+func unescape(s string) string {
+	return unescaper.Replace(s)
+}
@@ -0,0 +1,327 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+var (
+	exifHeader = []byte("Exif\x00\x00")
+	xmpHeaders = [][]byte{
+		[]byte("http://ns.adobe.com/xap/1.0/\x00"),
+		[]byte("http://ns.adobe.com/xmp/extension/\x00"),
+	}
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+)
+
+// VP8X flags announcing EXIF and XMP chunks in a WebP file.
+const (
+	vp8xFlagEXIF = 0x08
+	vp8xFlagXMP  = 0x04
+)
+
+const (
+	tagOrientation = 0x0112
+	tagGPSInfo     = 0x8825
+)
+
+// StripLocation removes GPS coordinates from JPEG and PNG images and returns
+// other content unchanged. In JPEG images the GPS part of the EXIF data is
+// blanked out in place, keeping the rest such as the orientation, and XMP
+// packets, which may repeat the location, are dropped. In PNG and WebP images
+// the EXIF and XMP chunks are dropped entirely.
+func StripLocation(b []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(b, []byte{0xff, 0xd8}):
+		return stripJPEG(b)
+	case bytes.HasPrefix(b, pngSignature):
+		return stripPNG(b)
+	case len(b) >= 12 && string(b[:4]) == "RIFF" && string(b[8:12]) == "WEBP":
+		return stripWebP(b)
+	default:
+		return b, nil
+	}
+}
+
+func stripJPEG(b []byte) ([]byte, error) {
+	out := make([]byte, 0, len(b))
+	out = append(out, b[:2]...)
+
+	i := 2
+	for {
+		if i+4 > len(b) || b[i] != 0xff {
+			return nil, ErrInvalidImage
+		}
+
+		marker := b[i+1]
+
+		// The metadata segments all come before the start of scan, after
+		// which the rest of the file is image data.
+		if marker == 0xda || marker == 0xd9 {
+			return append(out, b[i:]...), nil
+		}
+
+		end := i + 2 + int(binary.BigEndian.Uint16(b[i+2:]))
+		if end > len(b) || end < i+4 {
+			return nil, ErrInvalidImage
+		}
+
+		segment := b[i:end]
+		payload := segment[4:]
+
+		switch {
+		case marker == 0xe1 && bytes.HasPrefix(payload, exifHeader):
+			segment = bytes.Clone(segment)
+			if !blankGPS(segment[4+len(exifHeader):]) {
+				segment = nil
+			}
+		case marker == 0xe1 && isXMP(payload):
+			segment = nil
+		}
+
+		out = append(out, segment...)
+		i = end
+	}
+}
+
+func isXMP(payload []byte) bool {
+	for _, header := range xmpHeaders {
+		if bytes.HasPrefix(payload, header) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func stripPNG(b []byte) ([]byte, error) {
+	out := make([]byte, 0, len(b))
+	out = append(out, pngSignature...)
+
+	i := len(pngSignature)
+	for i < len(b) {
+		if i+12 > len(b) {
+			return nil, ErrInvalidImage
+		}
+
+		length := int(binary.BigEndian.Uint32(b[i:]))
+		end := i + 12 + length
+		if length < 0 || end > len(b) || end < i {
+			return nil, ErrInvalidImage
+		}
+
+		chunkType := string(b[i+4 : i+8])
+		chunkData := b[i+8 : i+8+length]
+
+		drop := chunkType == "eXIf" ||
+			chunkType == "iTXt" && bytes.HasPrefix(chunkData, []byte("XML:com.adobe.xmp\x00"))
+
+		if !drop {
+			out = append(out, b[i:end]...)
+		}
+
+		i = end
+	}
+
+	return out, nil
+}
+
+// stripWebP drops the EXIF and XMP chunks of a WebP file, clearing the flags
+// that announce them in the VP8X chunk and fixing up the RIFF size.
+func stripWebP(b []byte) ([]byte, error) {
+	out := make([]byte, 0, len(b))
+	out = append(out, b[:12]...)
+
+	i := 12
+	for i < len(b) {
+		if i+8 > len(b) {
+			return nil, ErrInvalidImage
+		}
+
+		size := int(binary.LittleEndian.Uint32(b[i+4:]))
+		// Chunks are padded to an even size.
+		end := i + 8 + size + size&1
+		if size < 0 || end > len(b) || end < i {
+			return nil, ErrInvalidImage
+		}
+
+		switch string(b[i : i+4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			if size < 1 {
+				return nil, ErrInvalidImage
+			}
+
+			chunk := bytes.Clone(b[i:end])
+			chunk[8] &^= vp8xFlagEXIF | vp8xFlagXMP
+			out = append(out, chunk...)
+		default:
+			out = append(out, b[i:end]...)
+		}
+
+		i = end
+	}
+
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+
+	return out, nil
+}
+
+// tiff reads the TIFF structure that EXIF data is stored in.
+type tiff struct {
+	b     []byte
+	order binary.ByteOrder
+}
+
+func newTIFF(b []byte) (*tiff, bool) {
+	if len(b) < 8 {
+		return nil, false
+	}
+
+	t := &tiff{b: b}
+
+	switch string(b[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, false
+	}
+
+	if t.order.Uint16(b[2:]) != 42 {
+		return nil, false
+	}
+
+	return t, true
+}
+
+// entries returns the offsets of the 12 byte entries of the IFD at off.
+func (t *tiff) entries(off uint32) ([]int, bool) {
+	if uint64(off)+2 > uint64(len(t.b)) {
+		return nil, false
+	}
+
+	n := int(t.order.Uint16(t.b[off:]))
+	start := int(off) + 2
+
+	if start+12*n > len(t.b) {
+		return nil, false
+	}
+
+	entries := make([]int, n)
+	for k := range entries {
+		entries[k] = start + 12*k
+	}
+
+	return entries, true
+}
+
+// find returns the offset of the entry with the given tag in the first IFD.
+func (t *tiff) find(tag uint16) (int, bool) {
+	entries, ok := t.entries(t.order.Uint32(t.b[4:]))
+	if !ok {
+		return 0, false
+	}
+
+	for _, e := range entries {
+		if t.order.Uint16(t.b[e:]) == tag {
+			return e, true
+		}
+	}
+
+	return 0, false
+}
+
+var tiffTypeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+// blankGPS zeroes the GPS IFD of the EXIF data in b, along with the values it
+// points to, and leaves it as an empty IFD. Offsets elsewhere stay valid as
+// nothing moves. It reports false if the data could not be parsed, in which
+// case the caller should drop it.
+func blankGPS(b []byte) bool {
+	t, ok := newTIFF(b)
+	if !ok {
+		return false
+	}
+
+	pointer, ok := t.find(tagGPSInfo)
+	if !ok {
+		_, ok = t.entries(t.order.Uint32(b[4:]))
+		return ok
+	}
+
+	gps := t.order.Uint32(b[pointer+8:])
+
+	entries, ok := t.entries(gps)
+	if !ok {
+		return false
+	}
+
+	for _, e := range entries {
+		size := tiffTypeSizes[t.order.Uint16(b[e+2:])] * int(t.order.Uint32(b[e+4:]))
+
+		if size > 4 {
+			off := int(t.order.Uint32(b[e+8:]))
+			if off < 0 || off+size > len(b) || off+size < off {
+				return false
+			}
+
+			clear(b[off : off+size])
+		}
+
+		clear(b[e : e+12])
+	}
+
+	// The IFD is followed by the offset of the next one, which for an empty
+	// IFD is read from the blanked first entry, or from here.
+	end := int(gps) + 2 + 12*len(entries)
+	if end+4 <= len(b) {
+		clear(b[end : end+4])
+	}
+
+	t.order.PutUint16(b[gps:], 0)
+
+	return true
+}
+
+// orientation returns the EXIF orientation of a JPEG image, from 1 to 8, or 1
+// if it has none.
+func orientation(b []byte) int {
+	if !bytes.HasPrefix(b, []byte{0xff, 0xd8}) {
+		return 1
+	}
+
+	i := 2
+	for i+4 <= len(b) && b[i] == 0xff && b[i+1] != 0xda {
+		end := i + 2 + int(binary.BigEndian.Uint16(b[i+2:]))
+		if end > len(b) {
+			break
+		}
+
+		payload := b[i+4 : end]
+
+		if b[i+1] == 0xe1 && bytes.HasPrefix(payload, exifHeader) {
+			t, ok := newTIFF(payload[len(exifHeader):])
+			if !ok {
+				return 1
+			}
+
+			e, ok := t.find(tagOrientation)
+			if !ok {
+				return 1
+			}
+
+			o := int(t.order.Uint16(t.b[e+8:]))
+			if o < 1 || o > 8 {
+				return 1
+			}
+
+			return o
+		}
+
+		i = end
+	}
+
+	return 1
+}
@@ -0,0 +1,311 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+)
+
+const (
+	gpsIFDOffset   = 38
+	gpsValueOffset = 68
+)
+
+// exifTIFF builds little-endian EXIF data with an orientation entry and a GPS
+// IFD holding a latitude reference stored inline and a latitude stored at
+// gpsValueOffset.
+func exifTIFF(orientation uint16) []byte {
+	le := binary.LittleEndian
+
+	b := make([]byte, gpsValueOffset+24)
+	copy(b, "II")
+	le.PutUint16(b[2:], 42)
+	le.PutUint32(b[4:], 8)
+
+	// IFD0 at 8: orientation and the GPS pointer, then no next IFD.
+	le.PutUint16(b[8:], 2)
+	putEntry(b[10:], tagOrientation, 3, 1, uint32(orientation))
+	putEntry(b[22:], tagGPSInfo, 4, 1, gpsIFDOffset)
+
+	// The GPS IFD: GPSLatitudeRef and GPSLatitude, three rationals.
+	le.PutUint16(b[gpsIFDOffset:], 2)
+	putEntry(b[gpsIFDOffset+2:], 1, 2, 2, uint32('N'))
+	putEntry(b[gpsIFDOffset+14:], 2, 5, 3, gpsValueOffset)
+
+	for i := gpsValueOffset; i < len(b); i++ {
+		b[i] = 0xaa
+	}
+
+	return b
+}
+
+func putEntry(b []byte, tag, typ uint16, count, value uint32) {
+	binary.LittleEndian.PutUint16(b, tag)
+	binary.LittleEndian.PutUint16(b[2:], typ)
+	binary.LittleEndian.PutUint32(b[4:], count)
+	binary.LittleEndian.PutUint32(b[8:], value)
+}
+
+func jpegWith(segments ...[]byte) []byte {
+	b := []byte{0xff, 0xd8}
+	for _, s := range segments {
+		b = append(b, s...)
+	}
+	return append(b, 0xff, 0xda, 0x00, 0x02, 0x01, 0x02, 0xff, 0xd9)
+}
+
+func segment(marker byte, payload []byte) []byte {
+	b := []byte{0xff, marker, 0, 0}
+	binary.BigEndian.PutUint16(b[2:], uint16(len(payload)+2))
+	return append(b, payload...)
+}
+
+func exifSegment(tiff []byte) []byte {
+	return segment(0xe1, append(bytes.Clone(exifHeader), tiff...))
+}
+
+func TestStripLocationJPEG(t *testing.T) {
+	jfif := segment(0xe0, []byte("JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00"))
+	xmp := segment(0xe1, append(bytes.Clone(xmpHeaders[0]), "<x:xmpmeta/>"...))
+
+	in := jpegWith(jfif, exifSegment(exifTIFF(6)), xmp)
+
+	out, err := StripLocation(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(out[2:], jfif) {
+		t.Error("JFIF segment was not kept first")
+	}
+	if bytes.Contains(out, []byte("xmpmeta")) {
+		t.Error("XMP packet was kept")
+	}
+	if bytes.Contains(out, []byte{0xaa}) {
+		t.Error("GPS values were kept")
+	}
+	if got := orientation(out); got != 6 {
+		t.Errorf("orientation = %d, want 6", got)
+	}
+	if len(out) != len(in)-len(xmp) {
+		t.Errorf("output is %d bytes, want %d", len(out), len(in)-len(xmp))
+	}
+
+	i := bytes.Index(out, exifHeader) + len(exifHeader)
+	if n := binary.LittleEndian.Uint16(out[i+gpsIFDOffset:]); n != 0 {
+		t.Errorf("GPS IFD has %d entries, want 0", n)
+	}
+
+	if !bytes.Contains(in, []byte{0xaa}) {
+		t.Error("the input was modified")
+	}
+}
+
+func TestStripLocationMalformedEXIF(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(b []byte) []byte
+	}{
+		{"short header", func(b []byte) []byte { return b[:6] }},
+		{"unknown byte order", func(b []byte) []byte { copy(b, "XX"); return b }},
+		{"bad magic number", func(b []byte) []byte { binary.LittleEndian.PutUint16(b[2:], 43); return b }},
+		{"IFD0 past the end", func(b []byte) []byte { binary.LittleEndian.PutUint32(b[4:], 1000); return b }},
+		{"IFD0 entries past the end", func(b []byte) []byte { binary.LittleEndian.PutUint16(b[8:], 100); return b }},
+		{"GPS IFD past the end", func(b []byte) []byte { binary.LittleEndian.PutUint32(b[30:], 1000); return b }},
+		{"GPS IFD truncated", func(b []byte) []byte { return b[:gpsIFDOffset+8] }},
+		{"GPS value past the end", func(b []byte) []byte {
+			binary.LittleEndian.PutUint32(b[gpsIFDOffset+22:], 1000)
+			return b
+		}},
+		{"GPS value count overflowing", func(b []byte) []byte {
+			binary.LittleEndian.PutUint32(b[gpsIFDOffset+18:], 0xffffffff)
+			return b
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := jpegWith(exifSegment(tt.modify(exifTIFF(1))))
+
+			out, err := StripLocation(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if bytes.Contains(out, exifHeader) {
+				t.Error("malformed EXIF segment was kept")
+			}
+			if !bytes.Equal(out, jpegWith()) {
+				t.Errorf("output = % x, want the image without the segment", out)
+			}
+		})
+	}
+}
+
+func TestStripLocationWithoutGPS(t *testing.T) {
+	tiff := exifTIFF(3)[:gpsIFDOffset]
+	binary.LittleEndian.PutUint16(tiff[8:], 1)
+
+	in := jpegWith(exifSegment(tiff))
+
+	out, err := StripLocation(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(out, in) {
+		t.Error("EXIF data without GPS was changed")
+	}
+	if got := orientation(out); got != 3 {
+		t.Errorf("orientation = %d, want 3", got)
+	}
+}
+
+func TestStripLocationInvalidJPEG(t *testing.T) {
+	tests := map[string][]byte{
+		"no segments":        {0xff, 0xd8},
+		"not a marker":       {0xff, 0xd8, 0x00, 0xe0, 0x00, 0x04},
+		"segment too long":   {0xff, 0xd8, 0xff, 0xe0, 0x00, 0x10, 0x00, 0x00},
+		"segment too short":  {0xff, 0xd8, 0xff, 0xe0, 0x00, 0x01, 0x00, 0x00},
+		"truncated at start": {0xff, 0xd8, 0xff},
+	}
+
+	for name, in := range tests {
+		_, err := StripLocation(in)
+		if !errors.Is(err, ErrInvalidImage) {
+			t.Errorf("%s: error = %v, want ErrInvalidImage", name, err)
+		}
+	}
+}
+
+func pngChunk(typ string, data []byte) []byte {
+	b := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	b = append(b, typ...)
+	b = append(b, data...)
+	return binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(b[4:]))
+}
+
+func TestStripLocationPNG(t *testing.T) {
+	ihdr := pngChunk("IHDR", make([]byte, 13))
+	text := pngChunk("iTXt", []byte("Comment\x00\x00\x00\x00\x00hello"))
+	idat := pngChunk("IDAT", []byte{1, 2, 3})
+	iend := pngChunk("IEND", nil)
+
+	in := bytes.Clone(pngSignature)
+	for _, c := range [][]byte{
+		ihdr,
+		pngChunk("eXIf", exifTIFF(1)),
+		pngChunk("iTXt", []byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00<x:xmpmeta/>")),
+		text,
+		idat,
+		iend,
+	} {
+		in = append(in, c...)
+	}
+
+	out, err := StripLocation(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := bytes.Clone(pngSignature)
+	for _, c := range [][]byte{ihdr, text, idat, iend} {
+		want = append(want, c...)
+	}
+
+	if !bytes.Equal(out, want) {
+		t.Errorf("output = % x, want % x", out, want)
+	}
+
+	_, err = StripLocation(in[:len(in)-3])
+	if !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("truncated PNG: error = %v, want ErrInvalidImage", err)
+	}
+}
+
+func webpChunk(fourCC string, data []byte) []byte {
+	b := append([]byte(fourCC), binary.LittleEndian.AppendUint32(nil, uint32(len(data)))...)
+	b = append(b, data...)
+	if len(data)%2 == 1 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func webp(chunks ...[]byte) []byte {
+	body := []byte("WEBP")
+	for _, c := range chunks {
+		body = append(body, c...)
+	}
+
+	b := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	return append(b, body...)
+}
+
+func TestStripLocationWebP(t *testing.T) {
+	vp8x := func(flags byte) []byte {
+		return webpChunk("VP8X", []byte{flags, 0, 0, 0, 1, 0, 0, 1, 0, 0})
+	}
+	image := webpChunk("VP8 ", []byte{1, 2, 3})
+
+	const alpha = 0x10
+
+	in := webp(
+		vp8x(alpha|vp8xFlagEXIF|vp8xFlagXMP),
+		image,
+		webpChunk("EXIF", exifTIFF(1)),
+		webpChunk("XMP ", []byte("<x:xmpmeta/>")),
+	)
+
+	out, err := StripLocation(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := webp(vp8x(alpha), image); !bytes.Equal(out, want) {
+		t.Errorf("output = % x, want % x", out, want)
+	}
+
+	for name, in := range map[string][]byte{
+		"truncated chunk header": in[:len(in)-4],
+		"chunk past the end":     webp(webpChunk("VP8 ", []byte{1, 2, 3}))[:22],
+		"empty VP8X":             webp(webpChunk("VP8X", nil)),
+	} {
+		_, err := StripLocation(in)
+		if !errors.Is(err, ErrInvalidImage) {
+			t.Errorf("%s: error = %v, want ErrInvalidImage", name, err)
+		}
+	}
+}
+
+func TestStripLocationOtherContent(t *testing.T) {
+	for _, in := range [][]byte{nil, []byte("GIF89a"), []byte("RIFF\x00\x00\x00\x00WAVE")} {
+		out, err := StripLocation(in)
+		if err != nil || !bytes.Equal(out, in) {
+			t.Errorf("StripLocation(%q) = %q, %v, want it unchanged", in, out, err)
+		}
+	}
+}
+
+func TestOrientation(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		want int
+	}{
+		{"rotated", jpegWith(exifSegment(exifTIFF(8))), 8},
+		{"out of range", jpegWith(exifSegment(exifTIFF(9))), 1},
+		{"zero", jpegWith(exifSegment(exifTIFF(0))), 1},
+		{"no EXIF", jpegWith(), 1},
+		{"malformed EXIF", jpegWith(exifSegment([]byte("II"))), 1},
+		{"not a JPEG", pngSignature, 1},
+	}
+
+	for _, tt := range tests {
+		if got := orientation(tt.in); got != tt.want {
+			t.Errorf("%s: orientation = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// Package imaging makes thumbnails of uploaded photos and removes location
+// data from them.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png"
+)
+
+var (
+	ErrInvalidImage = errors.New("invalid image")
+	ErrTooLarge     = errors.New("image too large")
+)
+
+// maxPixels guards against decompression bombs: small files that decode into
+// huge images. Making thumbnails holds the decoded image and an RGBA copy of
+// it, about 8 bytes a pixel, so the largest photo takes around 200MB.
+const maxPixels = 24_000_000
+
+// Size is a thumbnail variant, scaled down so that its longest edge is at
+// most Edge pixels.
+type Size struct {
+	Name string
+	Edge int
+}
+
+// Sizes are the thumbnail variants, largest first.
+var Sizes = []Size{
+	{Name: "large", Edge: 1024},
+	{Name: "medium", Edge: 480},
+	{Name: "small", Edge: 160},
+}
+
+// Thumbnailable reports whether thumbnails can be made of content of the
+// given type. WebP photos are accepted as attachments but get no thumbnails,
+// as there is no WebP decoder in the standard library; clients show them at
+// full size.
+func Thumbnailable(contentType string) bool {
+	return contentType == "image/jpeg" || contentType == "image/png"
+}
+
+// Thumbnails makes a JPEG thumbnail of each size, keyed by size name. Images
+// are turned upright according to their EXIF orientation, and transparent
+// areas are drawn over white. Images smaller than a size are not scaled up.
+func Thumbnails(b []byte) (map[string][]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	if config.Width*config.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	src := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(src, src.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(src, src.Bounds(), img, img.Bounds().Min, draw.Over)
+
+	o := orientation(b)
+	thumbnails := make(map[string][]byte, len(Sizes))
+
+	// Each size is scaled from the previous one, which is much cheaper than
+	// going back to the full image every time.
+	for _, size := range Sizes {
+		src = scale(src, size.Edge)
+
+		var buf bytes.Buffer
+
+		err = jpeg.Encode(&buf, orient(src, o), &jpeg.Options{Quality: 80})
+		if err != nil {
+			return nil, err
+		}
+
+		thumbnails[size.Name] = buf.Bytes()
+	}
+
+	return thumbnails, nil
+}
+
+// scale shrinks src so that its longest edge is at most edge pixels, by
+// averaging the source pixels that fall into each destination pixel.
+func scale(src *image.RGBA, edge int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	if sw <= edge && sh <= edge {
+		return src
+	}
+
+	dw, dh := edge, edge
+	if sw > sh {
+		dh = max(1, sh*edge/sw)
+	} else {
+		dw = max(1, sw*edge/sh)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for dy := 0; dy < dh; dy++ {
+		y0, y1 := dy*sh/dh, (dy+1)*sh/dh
+
+		for dx := 0; dx < dw; dx++ {
+			x0, x1 := dx*sw/dw, (dx+1)*sw/dw
+
+			var r, g, b, a, n int
+
+			for y := y0; y < y1; y++ {
+				row := src.Pix[y*src.Stride:]
+
+				for x := x0; x < x1; x++ {
+					p := row[x*4 : x*4+4]
+					r += int(p[0])
+					g += int(p[1])
+					b += int(p[2])
+					a += int(p[3])
+					n++
+				}
+			}
+
+			p := dst.Pix[dy*dst.Stride+dx*4 : dy*dst.Stride+dx*4+4]
+			p[0] = uint8(r / n)
+			p[1] = uint8(g / n)
+			p[2] = uint8(b / n)
+			p[3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
+
+// orient applies an EXIF orientation, 1 to 8, returning an upright image.
+func orient(src *image.RGBA, o int) *image.RGBA {
+	if o == 1 {
+		return src
+	}
+
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+
+			switch o {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+
+			copy(dst.Pix[dy*dst.Stride+dx*4:dy*dst.Stride+dx*4+4], src.Pix[y*src.Stride+x*4:y*src.Stride+x*4+4])
+		}
+	}
+
+	return dst
+}
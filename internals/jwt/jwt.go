@@ -0,0 +1,240 @@
+package jwt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("jwt: invalid token")
+	ErrExpiredToken = errors.New("jwt: token has expired")
+	ErrUnknownKey   = errors.New("jwt: unknown signing key")
+)
+
+var b64 = base64.RawURLEncoding
+
+type Header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+// RegisteredClaims holds the standard claims checked on every verification.
+// Application claims should embed it.
+type RegisteredClaims struct {
+	Issuer    string   `json:"iss,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	Expiry    int64    `json:"exp,omitempty"`
+}
+
+// Audience is a single audience or a list of them, both are valid in a JWT.
+type Audience []string
+
+func (a Audience) Contains(audience string) bool {
+	for i := range a {
+		if a[i] == audience {
+			return true
+		}
+	}
+	return false
+}
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+
+	*a = list
+	return nil
+}
+
+func (c RegisteredClaims) Valid(now time.Time) error {
+	if c.Expiry != 0 && now.Unix() >= c.Expiry {
+		return ErrExpiredToken
+	}
+
+	if c.NotBefore != 0 && now.Unix() < c.NotBefore {
+		return ErrInvalidToken
+	}
+
+	return nil
+}
+
+// Token is a decoded but unverified compact JWS.
+type Token struct {
+	Header       Header
+	Payload      []byte
+	SigningInput []byte
+	Signature    []byte
+}
+
+func Parse(token string) (*Token, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	headerJSON, err := b64.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := b64.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	signature, err := b64.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var t Token
+
+	err = json.Unmarshal(headerJSON, &t.Header)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	t.Payload = payload
+	t.SigningInput = []byte(parts[0] + "." + parts[1])
+	t.Signature = signature
+
+	return &t, nil
+}
+
+// Claims decodes the payload into dst and checks the registered claims.
+func (t *Token) Claims(dst any, now time.Time) error {
+	var registered RegisteredClaims
+
+	err := json.Unmarshal(t.Payload, &registered)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	err = registered.Valid(now)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(t.Payload, dst)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	return nil
+}
+
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// Keyring signs tokens with HS256 using its first key and verifies tokens
+// signed by any of its keys, selected by the kid header. Keys are rotated by
+// adding a new key in front and dropping the old one once its tokens expire.
+type Keyring struct {
+	keys []Key
+}
+
+func NewKeyring(keys ...Key) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("jwt: keyring needs at least one key")
+	}
+
+	seen := make(map[string]bool)
+
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("jwt: key id must not be empty")
+		}
+
+		if seen[key.ID] {
+			return nil, errors.New("jwt: duplicate key id " + key.ID)
+		}
+
+		if len(key.Secret) < 32 {
+			return nil, errors.New("jwt: key " + key.ID + " must be at least 32 bytes long")
+		}
+
+		seen[key.ID] = true
+	}
+
+	return &Keyring{keys: keys}, nil
+}
+
+func (k *Keyring) Sign(claims any) (string, error) {
+	key := k.keys[0]
+
+	header, err := json.Marshal(Header{Algorithm: "HS256", Type: "JWT", KeyID: key.ID})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := b64.EncodeToString(header) + "." + b64.EncodeToString(payload)
+
+	return signingInput + "." + b64.EncodeToString(sign(key.Secret, []byte(signingInput))), nil
+}
+
+func (k *Keyring) Verify(token string, claims any) error {
+	t, err := Parse(token)
+	if err != nil {
+		return err
+	}
+
+	if t.Header.Algorithm != "HS256" {
+		return ErrInvalidToken
+	}
+
+	var secret []byte
+	for _, key := range k.keys {
+		if key.ID == t.Header.KeyID {
+			secret = key.Secret
+			break
+		}
+	}
+
+	if secret == nil {
+		return ErrUnknownKey
+	}
+
+	if !hmac.Equal(t.Signature, sign(secret, t.SigningInput)) {
+		return ErrInvalidToken
+	}
+
+	return t.Claims(claims, time.Now())
+}
+
+func sign(secret, input []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(input)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,212 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed "common.txt"
+var commonPasswords []byte
+
+// Policy decides whether a password is acceptable beyond its length.
+type Policy struct {
+	MinScore int
+	list     *HashList
+}
+
+// New returns a policy that checks against the given list, or against the
+// embedded list of common passwords if list is nil.
+func New(minScore int, list *HashList) *Policy {
+	if list == nil {
+		list = embeddedList
+	}
+
+	return &Policy{MinScore: minScore, list: list}
+}
+
+var embeddedList = func() *HashList {
+	var passwords []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(commonPasswords))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			passwords = append(passwords, line)
+		}
+	}
+
+	return NewHashList(passwords)
+}()
+
+// Score rates a password from 0 (trivial) to 4 (strong) from a rough
+// estimate of its entropy. Repeated and sequential characters add little.
+func (p *Policy) Score(password string) int {
+	var lower, upper, digit, symbol, other bool
+
+	effective := 0.0
+	prev := rune(-1)
+
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r) && r < utf8.RuneSelf:
+			lower = true
+		case unicode.IsUpper(r) && r < utf8.RuneSelf:
+			upper = true
+		case unicode.IsDigit(r) && r < utf8.RuneSelf:
+			digit = true
+		case r < utf8.RuneSelf:
+			symbol = true
+		default:
+			other = true
+		}
+
+		if r == prev || r == prev+1 || r == prev-1 {
+			effective += 0.25
+		} else {
+			effective++
+		}
+
+		prev = r
+	}
+
+	pool := 0
+	for _, class := range []struct {
+		present bool
+		size    int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.present {
+			pool += class.size
+		}
+	}
+
+	if pool == 0 {
+		return 0
+	}
+
+	bits := effective * math.Log2(float64(pool))
+
+	switch {
+	case bits < 28:
+		return 0
+	case bits < 40:
+		return 1
+	case bits < 60:
+		return 2
+	case bits < 80:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// ContainsPersonalInfo reports whether the password contains the email
+// address, its local part or any word of at least 3 letters from the given
+// values, ignoring case.
+func (p *Policy) ContainsPersonalInfo(password string, values ...string) bool {
+	password = strings.ToLower(password)
+
+	for _, value := range values {
+		value = strings.ToLower(value)
+
+		words := strings.FieldsFunc(value, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+
+		if local, _, ok := strings.Cut(value, "@"); ok {
+			words = append(words, local)
+		}
+
+		for _, word := range words {
+			if utf8.RuneCountInString(word) >= 3 && strings.Contains(password, word) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Breached reports whether the password, or its lowercase form, is on the list.
+func (p *Policy) Breached(password string) bool {
+	return p.list.Contains(password) || p.list.Contains(strings.ToLower(password))
+}
+
+// HashList is a sorted list of 64-bit SHA-1 prefixes of passwords. At 8 bytes
+// per entry it stays small and a lookup is a binary search; a false positive
+// is about as likely as guessing the password.
+type HashList struct {
+	hashes []uint64
+}
+
+func passwordHash(password string) uint64 {
+	sum := sha1.Sum([]byte(password))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+func NewHashList(passwords []string) *HashList {
+	hashes := make([]uint64, 0, len(passwords))
+	for _, password := range passwords {
+		hashes = append(hashes, passwordHash(password))
+	}
+
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+
+	return &HashList{hashes: hashes}
+}
+
+func (l *HashList) Contains(password string) bool {
+	return l.containsHash(passwordHash(password))
+}
+
+func (l *HashList) containsHash(h uint64) bool {
+	i := sort.Search(len(l.hashes), func(i int) bool { return l.hashes[i] >= h })
+	return i < len(l.hashes) && l.hashes[i] == h
+}
+
+func (l *HashList) Len() int {
+	return len(l.hashes)
+}
+
+// WriteTo writes the list in the hash file format: the sorted prefixes as
+// big-endian 8 byte integers with nothing in between.
+func (l *HashList) WriteTo(w io.Writer) (int64, error) {
+	buf := make([]byte, 8*len(l.hashes))
+	for i, h := range l.hashes {
+		binary.BigEndian.PutUint64(buf[i*8:], h)
+	}
+
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+func ReadHashFile(path string) (*HashList, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(raw)%8 != 0 {
+		return nil, errors.New("passwordpolicy: hash file size is not a multiple of 8 bytes")
+	}
+
+	hashes := make([]uint64, len(raw)/8)
+	for i := range hashes {
+		hashes[i] = binary.BigEndian.Uint64(raw[i*8:])
+
+		if i > 0 && hashes[i] < hashes[i-1] {
+			return nil, errors.New("passwordpolicy: hash file is not sorted")
+		}
+	}
+
+	return &HashList{hashes: hashes}, nil
+}
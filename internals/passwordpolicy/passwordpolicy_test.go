@@ -0,0 +1,172 @@
+package passwordpolicy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		password string
+		want     int
+	}{
+		{"", 0},
+		{"aaaaaaaa", 0},
+		{"abcdefgh", 0},
+		{"Zx9!", 0},
+		{"password", 1},
+		{"abc123", 0},
+		{"Tr0ub4dor&3", 3},
+		{"correct horse battery staple", 4},
+	}
+
+	p := New(2, nil)
+
+	for _, tt := range tests {
+		if got := p.Score(tt.password); got != tt.want {
+			t.Errorf("Score(%q) = %d, want %d", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestContainsPersonalInfo(t *testing.T) {
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"xX-Priya-2024", true},
+		{"sharma!rocks", true},
+		{"p.sharma99", true},
+		{"my-example-pass", true},
+		{"unrelated words here", false},
+		{"al-quiet", false},
+	}
+
+	p := New(2, nil)
+
+	for _, tt := range tests {
+		got := p.ContainsPersonalInfo(tt.password, "p.sharma99@example.com", "Priya Al Sharma")
+		if got != tt.want {
+			t.Errorf("ContainsPersonalInfo(%q) = %t, want %t", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestBreached(t *testing.T) {
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"123456", true},
+		{"password", true},
+		{"PassWord", true},
+		{"correct horse battery staple", false},
+	}
+
+	p := New(2, nil)
+
+	for _, tt := range tests {
+		if got := p.Breached(tt.password); got != tt.want {
+			t.Errorf("Breached(%q) = %t, want %t", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestHashListSearch(t *testing.T) {
+	l := &HashList{hashes: []uint64{10, 20, 30, 1<<64 - 1}}
+
+	tests := []struct {
+		name string
+		hash uint64
+		want bool
+	}{
+		{"first", 10, true},
+		{"middle", 20, true},
+		{"last", 1<<64 - 1, true},
+		{"before the first", 0, false},
+		{"between entries", 25, false},
+		{"between the last two", 1 << 63, false},
+	}
+
+	for _, tt := range tests {
+		if got := l.containsHash(tt.hash); got != tt.want {
+			t.Errorf("%s: containsHash(%d) = %t, want %t", tt.name, tt.hash, got, tt.want)
+		}
+	}
+
+	if (&HashList{}).containsHash(0) {
+		t.Error("an empty list contains a hash")
+	}
+}
+
+func TestNewHashList(t *testing.T) {
+	passwords := []string{"zebra", "apple", "mango", "kiwi"}
+
+	l := NewHashList(passwords)
+
+	if l.Len() != len(passwords) {
+		t.Fatalf("Len = %d, want %d", l.Len(), len(passwords))
+	}
+
+	for i := 1; i < len(l.hashes); i++ {
+		if l.hashes[i] < l.hashes[i-1] {
+			t.Fatalf("hashes are not sorted: %v", l.hashes)
+		}
+	}
+
+	for _, password := range passwords {
+		if !l.Contains(password) {
+			t.Errorf("Contains(%q) = false, want true", password)
+		}
+	}
+
+	if l.Contains("banana") {
+		t.Error(`Contains("banana") = true, want false`)
+	}
+}
+
+func TestHashFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hashes")
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := NewHashList([]string{"one", "two", "three"})
+
+	_, err = l.WriteTo(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	read, err := ReadHashFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, password := range []string{"one", "two", "three"} {
+		if !read.Contains(password) {
+			t.Errorf("Contains(%q) = false after reading the file back", password)
+		}
+	}
+
+	for name, content := range map[string][]byte{
+		"truncated": {0, 0, 0, 0, 0, 0, 0, 1, 0},
+		"unsorted":  {0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 1},
+	} {
+		bad := filepath.Join(t.TempDir(), name)
+
+		err := os.WriteFile(bad, content, 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = ReadHashFile(bad)
+		if err == nil {
+			t.Errorf("%s: ReadHashFile accepted an invalid file", name)
+		}
+	}
+}
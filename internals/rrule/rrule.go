@@ -0,0 +1,308 @@
+// Package rrule implements the subset of RFC 5545 recurrence rules used for
+// recurring follow-ups: FREQ, INTERVAL, COUNT, UNTIL and BYMONTHDAY.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// maxEmptyPeriods bounds the search for the next occurrence, since some rules,
+// like the 31st of every February, never produce one.
+const maxEmptyPeriods = 1000
+
+const (
+	untilFormat     = "20060102T150405Z"
+	untilDateFormat = "20060102"
+)
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+type Rule struct {
+	Until      time.Time
+	Freq       Frequency
+	ByMonthDay []int
+	Interval   int
+	Count      int
+}
+
+// Parse reads a rule such as "FREQ=MONTHLY;INTERVAL=6" or
+// "FREQ=MONTHLY;BYMONTHDAY=1,15;COUNT=10". An optional "RRULE:" prefix is
+// accepted.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+
+	r := &Rule{Interval: 1}
+
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("%w: malformed part %q", ErrInvalidRule, part)
+		}
+
+		name = strings.ToUpper(name)
+		if seen[name] {
+			return nil, fmt.Errorf("%w: %s given more than once", ErrInvalidRule, name)
+		}
+		seen[name] = true
+
+		var err error
+
+		switch name {
+		case "FREQ":
+			r.Freq = Frequency(strings.ToUpper(value))
+			switch r.Freq {
+			case Daily, Weekly, Monthly, Yearly:
+			default:
+				return nil, fmt.Errorf("%w: unsupported FREQ %q", ErrInvalidRule, value)
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err != nil || r.Interval < 1 {
+				return nil, fmt.Errorf("%w: INTERVAL must be a positive integer", ErrInvalidRule)
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+			if err != nil || r.Count < 1 {
+				return nil, fmt.Errorf("%w: COUNT must be a positive integer", ErrInvalidRule)
+			}
+		case "UNTIL":
+			r.Until, err = time.Parse(untilFormat, value)
+			if err != nil {
+				r.Until, err = time.Parse(untilDateFormat, value)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%w: UNTIL must be a date or a UTC date-time", ErrInvalidRule)
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(day)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, fmt.Errorf("%w: BYMONTHDAY values must be between 1 and 31 or -31 and -1", ErrInvalidRule)
+				}
+				r.ByMonthDay = append(r.ByMonthDay, n)
+			}
+		default:
+			return nil, fmt.Errorf("%w: unsupported part %s", ErrInvalidRule, name)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	}
+
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, fmt.Errorf("%w: COUNT and UNTIL can not both be given", ErrInvalidRule)
+	}
+
+	if r.Freq == Weekly && len(r.ByMonthDay) > 0 {
+		return nil, fmt.Errorf("%w: BYMONTHDAY can not be used with FREQ=WEEKLY", ErrInvalidRule)
+	}
+
+	return r, nil
+}
+
+// String returns the rule in its canonical form.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilFormat))
+	}
+
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, day := range r.ByMonthDay {
+			days[i] = strconv.Itoa(day)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// After returns up to n occurrences of the series starting at dtstart that
+// fall strictly after t. As in RFC 5545, dtstart is always the first
+// occurrence and counts towards COUNT.
+func (r *Rule) After(dtstart, t time.Time, n int) []time.Time {
+	var occurrences []time.Time
+
+	r.each(dtstart, func(occurrence time.Time) bool {
+		if occurrence.After(t) {
+			occurrences = append(occurrences, occurrence)
+		}
+		return len(occurrences) < n
+	})
+
+	return occurrences
+}
+
+// Next returns the first occurrence after t, if the series has one.
+func (r *Rule) Next(dtstart, t time.Time) (time.Time, bool) {
+	occurrences := r.After(dtstart, t, 1)
+	if len(occurrences) == 0 {
+		return time.Time{}, false
+	}
+
+	return occurrences[0], true
+}
+
+// each calls fn with every occurrence in order until fn returns false or the
+// series ends.
+func (r *Rule) each(dtstart time.Time, fn func(time.Time) bool) {
+	count := 0
+
+	emit := func(occurrence time.Time) bool {
+		if !r.Until.IsZero() && occurrence.After(r.Until) {
+			return false
+		}
+
+		count++
+		if !fn(occurrence) {
+			return false
+		}
+
+		return r.Count == 0 || count < r.Count
+	}
+
+	if !emit(dtstart) {
+		return
+	}
+
+	empty := 0
+
+	for period := 0; empty < maxEmptyPeriods; period++ {
+		found := false
+
+		for _, candidate := range r.candidates(dtstart, period) {
+			if !candidate.After(dtstart) {
+				continue
+			}
+
+			if !emit(candidate) {
+				return
+			}
+			found = true
+		}
+
+		if found {
+			empty = 0
+		} else {
+			empty++
+		}
+	}
+}
+
+// candidates returns the sorted occurrences within the period-th period of
+// the series, counting from the one containing dtstart.
+func (r *Rule) candidates(dtstart time.Time, period int) []time.Time {
+	year, month, day := dtstart.Date()
+	hour, min, sec := dtstart.Clock()
+	loc := dtstart.Location()
+
+	step := period * r.Interval
+
+	switch r.Freq {
+	case Daily:
+		t := dtstart.AddDate(0, 0, step)
+		if len(r.ByMonthDay) > 0 && !r.matchesMonthDay(t) {
+			return nil
+		}
+		return []time.Time{t}
+	case Weekly:
+		return []time.Time{dtstart.AddDate(0, 0, 7*step)}
+	case Monthly:
+		first := time.Date(year, month+time.Month(step), 1, hour, min, sec, 0, loc)
+		return r.monthDays(first, day)
+	case Yearly:
+		if len(r.ByMonthDay) == 0 {
+			t := time.Date(year+step, month, day, hour, min, sec, 0, loc)
+			if t.Day() != day {
+				return nil
+			}
+			return []time.Time{t}
+		}
+
+		var days []time.Time
+		for m := time.January; m <= time.December; m++ {
+			days = append(days, r.monthDays(time.Date(year+step, m, 1, hour, min, sec, 0, loc), day)...)
+		}
+		return days
+	}
+
+	return nil
+}
+
+// monthDays returns the days of the month starting at first that match
+// BYMONTHDAY, or the given day when BYMONTHDAY is empty. Days the month
+// doesn't have are skipped.
+func (r *Rule) monthDays(first time.Time, day int) []time.Time {
+	length := daysIn(first)
+
+	wanted := r.ByMonthDay
+	if len(wanted) == 0 {
+		wanted = []int{day}
+	}
+
+	var days []int
+	for _, d := range wanted {
+		if d < 0 {
+			d = length + d + 1
+		}
+
+		if d >= 1 && d <= length {
+			days = append(days, d)
+		}
+	}
+
+	sort.Ints(days)
+
+	var occurrences []time.Time
+	for i, d := range days {
+		if i > 0 && d == days[i-1] {
+			continue
+		}
+		occurrences = append(occurrences, first.AddDate(0, 0, d-1))
+	}
+
+	return occurrences
+}
+
+func (r *Rule) matchesMonthDay(t time.Time) bool {
+	length := daysIn(t)
+
+	for _, d := range r.ByMonthDay {
+		if d == t.Day() || length+d+1 == t.Day() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func daysIn(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+}
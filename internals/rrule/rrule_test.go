@@ -0,0 +1,212 @@
+package rrule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 9, 0, 0, 0, time.UTC)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"RRULE:FREQ=MONTHLY;INTERVAL=6", "FREQ=MONTHLY;INTERVAL=6"},
+		{"freq=weekly;interval=1", "FREQ=WEEKLY"},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,15;COUNT=10", "FREQ=MONTHLY;COUNT=10;BYMONTHDAY=1,15"},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1", "FREQ=MONTHLY;BYMONTHDAY=-1"},
+		{"FREQ=YEARLY;UNTIL=20301231", "FREQ=YEARLY;UNTIL=20301231T000000Z"},
+		{"FREQ=YEARLY;UNTIL=20301231T120000Z", "FREQ=YEARLY;UNTIL=20301231T120000Z"},
+	}
+
+	for _, tt := range tests {
+		r, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if got := r.String(); got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;COUNT=x",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=DAILY;COUNT=3;UNTIL=20301231",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYMONTHDAY=-32",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=DAILY;BYDAY=MO",
+		"FREQ=DAILY;INTERVAL",
+	}
+
+	for _, in := range tests {
+		_, err := Parse(in)
+		if !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("Parse(%q) error = %v, want ErrInvalidRule", in, err)
+		}
+	}
+}
+
+func TestAfter(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart time.Time
+		after   time.Time
+		n       int
+		want    []time.Time
+	}{
+		{
+			name:    "daily",
+			rule:    "FREQ=DAILY;INTERVAL=2",
+			dtstart: date(2024, time.January, 30),
+			after:   date(2024, time.January, 30),
+			n:       3,
+			want:    []time.Time{date(2024, time.February, 1), date(2024, time.February, 3), date(2024, time.February, 5)},
+		},
+		{
+			name:    "weekly",
+			rule:    "FREQ=WEEKLY",
+			dtstart: date(2024, time.March, 1),
+			after:   date(2024, time.March, 1),
+			n:       2,
+			want:    []time.Time{date(2024, time.March, 8), date(2024, time.March, 15)},
+		},
+		{
+			name:    "monthly skips months without the day",
+			rule:    "FREQ=MONTHLY",
+			dtstart: date(2024, time.January, 31),
+			after:   date(2024, time.January, 31),
+			n:       3,
+			want:    []time.Time{date(2024, time.March, 31), date(2024, time.May, 31), date(2024, time.July, 31)},
+		},
+		{
+			name:    "negative BYMONTHDAY is the last day of each month",
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=-1",
+			dtstart: date(2024, time.January, 31),
+			after:   date(2024, time.January, 31),
+			n:       3,
+			want:    []time.Time{date(2024, time.February, 29), date(2024, time.March, 31), date(2024, time.April, 30)},
+		},
+		{
+			name:    "negative and positive BYMONTHDAY naming the same day",
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=30,-1",
+			dtstart: date(2024, time.April, 1),
+			after:   date(2024, time.April, 1),
+			n:       3,
+			want:    []time.Time{date(2024, time.April, 30), date(2024, time.May, 30), date(2024, time.May, 31)},
+		},
+		{
+			name:    "daily with negative BYMONTHDAY",
+			rule:    "FREQ=DAILY;BYMONTHDAY=-2",
+			dtstart: date(2023, time.February, 1),
+			after:   date(2023, time.February, 1),
+			n:       2,
+			want:    []time.Time{date(2023, time.February, 27), date(2023, time.March, 30)},
+		},
+		{
+			name:    "yearly on February 29 only falls in leap years",
+			rule:    "FREQ=YEARLY",
+			dtstart: date(2024, time.February, 29),
+			after:   date(2024, time.February, 29),
+			n:       2,
+			want:    []time.Time{date(2028, time.February, 29), date(2032, time.February, 29)},
+		},
+		{
+			name:    "yearly with BYMONTHDAY",
+			rule:    "FREQ=YEARLY;BYMONTHDAY=-1",
+			dtstart: date(2024, time.October, 31),
+			after:   date(2024, time.October, 31),
+			n:       3,
+			want:    []time.Time{date(2024, time.November, 30), date(2024, time.December, 31), date(2025, time.January, 31)},
+		},
+		{
+			name:    "COUNT includes dtstart",
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: date(2024, time.May, 1),
+			after:   date(2024, time.April, 1),
+			n:       10,
+			want:    []time.Time{date(2024, time.May, 1), date(2024, time.May, 2), date(2024, time.May, 3)},
+		},
+		{
+			name:    "COUNT counts occurrences before t",
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: date(2024, time.May, 1),
+			after:   date(2024, time.May, 1),
+			n:       10,
+			want:    []time.Time{date(2024, time.May, 2), date(2024, time.May, 3)},
+		},
+		{
+			name:    "COUNT of one is only dtstart",
+			rule:    "FREQ=WEEKLY;COUNT=1",
+			dtstart: date(2024, time.May, 1),
+			after:   date(2024, time.May, 1),
+			n:       10,
+			want:    nil,
+		},
+		{
+			name:    "UNTIL is inclusive",
+			rule:    "FREQ=DAILY;UNTIL=20240503T090000Z",
+			dtstart: date(2024, time.May, 1),
+			after:   date(2024, time.May, 1),
+			n:       10,
+			want:    []time.Time{date(2024, time.May, 2), date(2024, time.May, 3)},
+		},
+		{
+			name:    "a day no month has never occurs",
+			rule:    "FREQ=YEARLY;BYMONTHDAY=31",
+			dtstart: date(2024, time.February, 1),
+			after:   date(2024, time.December, 31),
+			n:       1,
+			want:    []time.Time{date(2025, time.January, 31)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := r.After(tt.dtstart, tt.after, tt.n)
+			if len(got) != len(tt.want) {
+				t.Fatalf("After = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("After[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNextImpossibleRule(t *testing.T) {
+	// Starting in February, only Februaries are searched, which never have
+	// a 30th.
+	r, err := Parse("FREQ=MONTHLY;INTERVAL=12;BYMONTHDAY=30")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if next, ok := r.Next(date(2024, time.February, 1), date(2024, time.February, 1)); ok {
+		t.Errorf("Next = %v, want none", next)
+	}
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPrefix = "https://api.example.com/v1/files/"
+
+func newTestLocal(t *testing.T) *Local {
+	t.Helper()
+
+	l, err := NewLocal(t.TempDir(), testPrefix, bytes.Repeat([]byte("k"), 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return l
+}
+
+// signedQuery returns the query of a URL from SignedURL, checking that it
+// points at the key under the URL prefix.
+func signedQuery(t *testing.T, l *Local, key, filename string, expiry time.Duration) url.Values {
+	t.Helper()
+
+	signed, err := l.SignedURL(key, filename, expiry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(signed, testPrefix+key+"?") {
+		t.Fatalf("SignedURL = %s, want it under %s%s", signed, testPrefix, key)
+	}
+
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u.Query()
+}
+
+func TestLocalSignVerify(t *testing.T) {
+	l := newTestLocal(t)
+
+	q := signedQuery(t, l, "abc123", "brake pads.pdf", time.Minute)
+
+	filename, err := l.Verify("abc123", q)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if filename != "brake pads.pdf" {
+		t.Errorf("Verify filename = %q, want %q", filename, "brake pads.pdf")
+	}
+}
+
+func TestLocalVerifyRejects(t *testing.T) {
+	l := newTestLocal(t)
+
+	tests := map[string]struct {
+		key    string
+		modify func(q url.Values)
+	}{
+		"other key":         {"abc124", func(q url.Values) {}},
+		"changed filename":  {"abc123", func(q url.Values) { q.Set("filename", "other.pdf") }},
+		"extended expiry":   {"abc123", func(q url.Values) { q.Set("expires", "99999999999") }},
+		"changed signature": {"abc123", func(q url.Values) { q.Set("signature", strings.Repeat("0", 64)) }},
+		"invalid signature": {"abc123", func(q url.Values) { q.Set("signature", "zz") }},
+		"missing expiry":    {"abc123", func(q url.Values) { q.Del("expires") }},
+	}
+
+	for name, tt := range tests {
+		q := signedQuery(t, l, "abc123", "brake pads.pdf", time.Minute)
+		tt.modify(q)
+
+		if _, err := l.Verify(tt.key, q); err != ErrInvalidSignature {
+			t.Errorf("%s: got %v, want ErrInvalidSignature", name, err)
+		}
+	}
+}
+
+func TestLocalVerifyExpired(t *testing.T) {
+	l := newTestLocal(t)
+
+	q := signedQuery(t, l, "abc123", "", -time.Second)
+
+	if _, err := l.Verify("abc123", q); err != ErrInvalidSignature {
+		t.Errorf("got %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestLocalVerifyOtherSecret(t *testing.T) {
+	l := newTestLocal(t)
+	other, err := NewLocal(t.TempDir(), testPrefix, bytes.Repeat([]byte("o"), 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := signedQuery(t, other, "abc123", "", time.Minute)
+
+	if _, err := l.Verify("abc123", q); err != ErrInvalidSignature {
+		t.Errorf("got %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestLocalObjects(t *testing.T) {
+	l := newTestLocal(t)
+	ctx := context.Background()
+
+	content := []byte("%PDF-1.4 test")
+
+	err := l.Put(ctx, "abcdef", bytes.NewReader(content), int64(len(content)), "application/pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exists, err := l.Exists(ctx, "abcdef")
+	if err != nil || !exists {
+		t.Fatalf("Exists = %v, %v; want true", exists, err)
+	}
+
+	object, err := l.Get(ctx, "abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(object)
+	object.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("Get = %q, want %q", got, content)
+	}
+
+	if err := l.Delete(ctx, "abcdef"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Delete(ctx, "abcdef"); err != nil {
+		t.Errorf("deleting a missing object: %v", err)
+	}
+
+	if _, err := l.Get(ctx, "abcdef"); err != ErrNotFound {
+		t.Errorf("Get after Delete: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestLocalInvalidKeys(t *testing.T) {
+	l := newTestLocal(t)
+	ctx := context.Background()
+
+	for _, key := range []string{"", "../secret", "ABC", "a/b", "a.b"} {
+		if err := l.Put(ctx, key, strings.NewReader("x"), 1, "text/plain"); err != errInvalidKey {
+			t.Errorf("Put(%q): got %v, want errInvalidKey", key, err)
+		}
+		if _, err := l.Get(ctx, key); err != ErrNotFound {
+			t.Errorf("Get(%q): got %v, want ErrNotFound", key, err)
+		}
+		if _, err := l.SignedURL(key, "", time.Minute); err != errInvalidKey {
+			t.Errorf("SignedURL(%q): got %v, want errInvalidKey", key, err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Package storage keeps uploaded files in a local directory or an
+// S3-compatible bucket.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+var (
+	ErrNotFound         = errors.New("object not found")
+	ErrInvalidSignature = errors.New("invalid or expired signature")
+
+	errInvalidKey = errors.New("invalid object key")
+)
+
+// Storage stores objects by key. Keys are made of lower case letters, digits
+// and dashes, such as hex encoded content hashes.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	Delete(ctx context.Context, key string) error
+
+	// SignedURL returns a URL that downloads the object without any other
+	// credentials until expiry.
+	SignedURL(key, filename string, expiry time.Duration) (string, error)
+}
+
+func validKey(key string) bool {
+	if key == "" || len(key) > 200 {
+		return false
+	}
+
+	for _, c := range key {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ContentDisposition returns a Content-Disposition header value that
+// downloads a file under the given name.
+func ContentDisposition(filename string) string {
+	return fmt.Sprintf("attachment; filename*=UTF-8''%s", uriEncode(filename, true))
+}
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS impersonation_sessions (
+    id bigserial PRIMARY KEY,
+    token_hash bytea NOT NULL UNIQUE,
+    admin_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    reason text NOT NULL,
+    elevated boolean NOT NULL DEFAULT false,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    expiry timestamp(0) with time zone NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS impersonation_audit_log (
+    id bigserial PRIMARY KEY,
+    session_id bigint NOT NULL,
+    admin_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    method text NOT NULL,
+    path text NOT NULL,
+    status integer NOT NULL,
+    ip text NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS impersonation_audit_log_session_id_idx ON impersonation_audit_log (session_id);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS user_logins (
+    id bigserial PRIMARY KEY,
+    user_id bigint REFERENCES users ON DELETE CASCADE,
+    email citext NOT NULL,
+    method text NOT NULL,
+    ip text NOT NULL,
+    user_agent text NOT NULL,
+    fingerprint bytea NOT NULL,
+    success boolean NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS user_logins_user_id_created_at_idx ON user_logins (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS user_logins_user_id_fingerprint_idx ON user_logins (user_id, fingerprint) WHERE success;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS invitations (
+    id bigserial PRIMARY KEY,
+    organization_id bigint NOT NULL REFERENCES organizations ON DELETE CASCADE,
+    email citext NOT NULL,
+    role text NOT NULL,
+    token_hash bytea NOT NULL UNIQUE,
+    invited_by bigint REFERENCES users ON DELETE SET NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    expiry timestamp(0) with time zone NOT NULL,
+    accepted_at timestamp(0) with time zone
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS invitations_pending_email_idx ON invitations (organization_id, email) WHERE accepted_at IS NULL;
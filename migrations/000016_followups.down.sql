@@ -0,0 +1,3 @@
+DELETE FROM permissions WHERE code IN ('followups:read', 'followups:write');
+DROP TABLE IF EXISTS followup_transitions;
+DROP TABLE IF EXISTS followups;
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS followups (
+    id bigserial PRIMARY KEY,
+    vehicle_id bigint NOT NULL REFERENCES vehicles ON DELETE CASCADE,
+    assigned_to bigint REFERENCES users ON DELETE SET NULL,
+    title text NOT NULL,
+    notes text NOT NULL DEFAULT '',
+    due_at timestamp(0) with time zone NOT NULL,
+    status text NOT NULL DEFAULT 'pending',
+    status_reason text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS followups_assigned_to_idx ON followups (assigned_to);
+CREATE INDEX IF NOT EXISTS followups_vehicle_id_idx ON followups (vehicle_id);
+
+CREATE TABLE IF NOT EXISTS followup_transitions (
+    id bigserial PRIMARY KEY,
+    followup_id bigint NOT NULL REFERENCES followups ON DELETE CASCADE,
+    from_status text NOT NULL,
+    to_status text NOT NULL,
+    reason text NOT NULL DEFAULT '',
+    user_id bigint REFERENCES users ON DELETE SET NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS followup_transitions_followup_id_idx ON followup_transitions (followup_id);
+
+INSERT INTO permissions (code)
+VALUES ('followups:read'), ('followups:write')
+ON CONFLICT DO NOTHING;
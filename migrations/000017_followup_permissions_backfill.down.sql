@@ -0,0 +1 @@
+-- The backfilled grants can't be told apart from later ones, so they are kept.
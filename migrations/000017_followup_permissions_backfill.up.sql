@@ -0,0 +1,7 @@
+INSERT INTO users_permissions
+SELECT up.user_id, f.id
+FROM users_permissions up
+INNER JOIN permissions v ON v.id = up.permission_id
+INNER JOIN permissions f ON f.code = replace(v.code, 'vehicles:', 'followups:')
+WHERE v.code IN ('vehicles:read', 'vehicles:write')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,3 @@
+ALTER TABLE followups DROP COLUMN IF EXISTS previous_id;
+ALTER TABLE followups DROP COLUMN IF EXISTS recurrence_start;
+ALTER TABLE followups DROP COLUMN IF EXISTS recurrence;
@@ -0,0 +1,3 @@
+ALTER TABLE followups ADD COLUMN IF NOT EXISTS recurrence text NOT NULL DEFAULT '';
+ALTER TABLE followups ADD COLUMN IF NOT EXISTS recurrence_start timestamp(0) with time zone;
+ALTER TABLE followups ADD COLUMN IF NOT EXISTS previous_id bigint REFERENCES followups ON DELETE SET NULL;
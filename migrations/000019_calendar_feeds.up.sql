@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS calendar_feeds (
+    user_id bigint PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+    token_hash bytea NOT NULL UNIQUE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
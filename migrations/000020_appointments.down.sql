@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS appointments;
+DROP TABLE IF EXISTS service_bays;
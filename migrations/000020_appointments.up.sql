@@ -0,0 +1,24 @@
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+CREATE TABLE IF NOT EXISTS service_bays (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL UNIQUE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS appointments (
+    id bigserial PRIMARY KEY,
+    vehicle_id bigint NOT NULL REFERENCES vehicles ON DELETE CASCADE,
+    service_bay_id bigint NOT NULL REFERENCES service_bays ON DELETE CASCADE,
+    followup_id bigint REFERENCES followups ON DELETE SET NULL,
+    starts_at timestamp(0) with time zone NOT NULL,
+    ends_at timestamp(0) with time zone NOT NULL,
+    notes text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1,
+    CONSTRAINT appointments_period_check CHECK (ends_at > starts_at),
+    CONSTRAINT appointments_no_overlap EXCLUDE USING gist (service_bay_id WITH =, tstzrange(starts_at, ends_at) WITH &&)
+);
+
+CREATE INDEX IF NOT EXISTS appointments_followup_id_idx ON appointments (followup_id);
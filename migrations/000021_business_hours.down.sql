@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS holidays;
+DROP TABLE IF EXISTS business_hours;
+ALTER TABLE organizations DROP COLUMN IF EXISTS timezone;
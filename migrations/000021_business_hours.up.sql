@@ -0,0 +1,18 @@
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS timezone text NOT NULL DEFAULT 'UTC';
+
+CREATE TABLE IF NOT EXISTS business_hours (
+    organization_id bigint NOT NULL REFERENCES organizations ON DELETE CASCADE,
+    weekday smallint NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    opens time(0) NOT NULL,
+    closes time(0) NOT NULL,
+    PRIMARY KEY (organization_id, weekday),
+    CHECK (closes > opens)
+);
+
+CREATE TABLE IF NOT EXISTS holidays (
+    id bigserial PRIMARY KEY,
+    organization_id bigint NOT NULL REFERENCES organizations ON DELETE CASCADE,
+    date date NOT NULL,
+    name text NOT NULL,
+    UNIQUE (organization_id, date)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS business_hours_set boolean NOT NULL DEFAULT false;
+
+UPDATE organizations SET business_hours_set = true
+WHERE id IN (SELECT organization_id FROM business_hours);
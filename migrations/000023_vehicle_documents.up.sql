@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS vehicle_documents (
+    id bigserial PRIMARY KEY,
+    vehicle_id bigint NOT NULL REFERENCES vehicles ON DELETE CASCADE,
+    type text NOT NULL,
+    number text NOT NULL,
+    issuer text NOT NULL DEFAULT '',
+    valid_from date NOT NULL,
+    valid_until date NOT NULL,
+    followup_id bigint REFERENCES followups ON DELETE SET NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1,
+    CHECK (valid_until >= valid_from)
+);
+
+CREATE INDEX IF NOT EXISTS vehicle_documents_vehicle_id_idx ON vehicle_documents (vehicle_id);
+CREATE INDEX IF NOT EXISTS vehicle_documents_valid_until_idx ON vehicle_documents (valid_until);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS vehicle_attachments (
+    id bigserial PRIMARY KEY,
+    vehicle_id bigint NOT NULL REFERENCES vehicles ON DELETE CASCADE,
+    uploaded_by bigint REFERENCES users ON DELETE SET NULL,
+    filename text NOT NULL,
+    content_type text NOT NULL,
+    size bigint NOT NULL,
+    sha256 bytea NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS vehicle_attachments_vehicle_sha256_idx ON vehicle_attachments (vehicle_id, sha256);
+CREATE INDEX IF NOT EXISTS vehicle_attachments_sha256_idx ON vehicle_attachments (sha256);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS attachment_blobs (
+    sha256 bytea PRIMARY KEY,
+    orphaned_at timestamp(0) with time zone
+);
+
+INSERT INTO attachment_blobs (sha256)
+SELECT DISTINCT sha256 FROM vehicle_attachments
+ON CONFLICT DO NOTHING;
@@ -0,0 +1 @@
+ALTER TABLE vehicle_attachments ADD COLUMN IF NOT EXISTS thumbnails text[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS comments (
+    id bigserial PRIMARY KEY,
+    vehicle_id bigint REFERENCES vehicles ON DELETE CASCADE,
+    followup_id bigint REFERENCES followups ON DELETE CASCADE,
+    author_id bigint REFERENCES users ON DELETE SET NULL,
+    body text NOT NULL,
+    mentions bigint[] NOT NULL DEFAULT '{}',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    edited_at timestamp(0) with time zone,
+    version integer NOT NULL DEFAULT 1,
+    CHECK ((vehicle_id IS NULL) <> (followup_id IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS comments_vehicle_id_idx ON comments (vehicle_id);
+CREATE INDEX IF NOT EXISTS comments_followup_id_idx ON comments (followup_id);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS call_logs (
+    id bigserial PRIMARY KEY,
+    followup_id bigint NOT NULL REFERENCES followups ON DELETE CASCADE,
+    vehicle_id bigint NOT NULL REFERENCES vehicles ON DELETE CASCADE,
+    advisor_id bigint REFERENCES users ON DELETE SET NULL,
+    direction text NOT NULL,
+    number text NOT NULL,
+    duration integer NOT NULL CHECK (duration >= 0),
+    outcome text NOT NULL,
+    next_call_at timestamp(0) with time zone,
+    notes text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS call_logs_followup_id_idx ON call_logs (followup_id);
+CREATE INDEX IF NOT EXISTS call_logs_advisor_id_created_at_idx ON call_logs (advisor_id, created_at);